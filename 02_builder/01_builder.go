@@ -15,6 +15,8 @@ package main
 
 import (
 	"fmt"
+	"html"
+	"regexp"
 	"strings"
 )
 
@@ -47,29 +49,63 @@ const (
 type HTMLElement struct {
 	name, text string
 	elements   []HTMLElement
+	raw        bool
 }
 
 func (e *HTMLElement) String() string {
-	return e.string(0)
+	return e.Render(RenderOptions{})
 }
 
-func (e *HTMLElement) string(indent int) string {
+// Indentation is great while we're debugging, but when we
+// actually serve the HTML, all those spaces and newlines are
+// just extra bytes, so we can also ask for it all on one line.
+
+type RenderOptions struct {
+	Minify bool
+}
+
+// <- The zero value is the pretty, indented output.
+
+func (e *HTMLElement) Render(opts RenderOptions) string {
 	sb := strings.Builder{}
-	i := strings.Repeat(" ", indentSize*indent)
-	sb.WriteString(fmt.Sprintf("%s<%s>\n", i, e.name))
+	e.render(&sb, opts, 0)
+	return sb.String()
+}
+
+func (e *HTMLElement) render(sb *strings.Builder, opts RenderOptions, indent int) {
+	i, ti, nl := "", "", ""
+	if !opts.Minify {
+		i = strings.Repeat(" ", indentSize*indent)
+		ti = strings.Repeat(" ", indentSize*(indent+1))
+		nl = "\n"
+	}
+	sb.WriteString(fmt.Sprintf("%s<%s>%s", i, e.name, nl))
 
 	if len(e.text) > 0 {
-		sb.WriteString(strings.Repeat(" ", indentSize*(indent+1)))
-		sb.WriteString(e.text)
-		sb.WriteString("\n")
+		sb.WriteString(ti)
+		sb.WriteString(e.escapedText())
+		sb.WriteString(nl)
 	}
 
 	for _, el := range e.elements {
-		sb.WriteString(el.string(indent + 1))
+		el.render(sb, opts, indent+1)
 	}
 
-	sb.WriteString(fmt.Sprintf("%s<%s>\n", i, e.name))
-	return sb.String()
+	sb.WriteString(fmt.Sprintf("%s<%s>%s", i, e.name, nl))
+}
+
+// <- Every element writes into the same builder, instead of
+//	  building its own string and handing it up to its parent.
+
+// The text is whatever the user gave us, so something like
+// "fish & chips" or "a < b" would end up as broken HTML.
+// Unless we're told the text is trusted, we escape it.
+
+func (e *HTMLElement) escapedText() string {
+	if e.raw {
+		return e.text
+	}
+	return html.EscapeString(e.text)
 }
 
 // This HTML Builder now only cares about the root element.
@@ -100,9 +136,43 @@ func (b *HTMLBuilder) String() string {
 	return b.root.String()
 }
 
-func (b *HTMLBuilder) AddChild(name, text string) {
-	e := HTMLElement{name, text, []HTMLElement{}}
+func (b *HTMLBuilder) Render(opts RenderOptions) string {
+	return b.root.Render(opts)
+}
+
+// But we shouldn't just accept any name for an element.
+// Something like "<script>" or an empty string would leave
+// us with completely malformed HTML, so we'll only allow
+// letters, digits and hyphens.
+
+var tagNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+func validateTagName(name string) error {
+	if !tagNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid tag name %q", name)
+	}
+	return nil
+}
+
+func (b *HTMLBuilder) AddChild(name, text string) error {
+	if err := validateTagName(name); err != nil {
+		return err
+	}
+	e := HTMLElement{name: name, text: text, elements: []HTMLElement{}}
 	b.root.elements = append(b.root.elements, e)
+	return nil
+}
+
+// For content we trust, like a snippet of markup that we've
+// produced ourselves, there's a way to opt out of the escaping.
+
+func (b *HTMLBuilder) AddRawChild(name, text string) error {
+	if err := validateTagName(name); err != nil {
+		return err
+	}
+	e := HTMLElement{name: name, text: text, elements: []HTMLElement{}, raw: true}
+	b.root.elements = append(b.root.elements, e)
+	return nil
 }
 
 // Now the end user just need to care about the utility calls.
@@ -116,9 +186,16 @@ func (b *HTMLBuilder) AddChild(name, text string) {
 // but I can live with that.
 
 func (b *HTMLBuilder) AddChildFluent(name, text string) *HTMLBuilder {
-	e := HTMLElement{name, text, []HTMLElement{}}
-	b.root.elements = append(b.root.elements, e)
+	return b.MustAddChild(name, text)
+}
+
+// <- There's no room for an error in a fluent chain, so if
+//	  the tag name is invalid, we panic instead.
 
+func (b *HTMLBuilder) MustAddChild(name, text string) *HTMLBuilder {
+	if err := b.AddChild(name, text); err != nil {
+		panic(err)
+	}
 	return b
 }
 
@@ -152,4 +229,20 @@ func main() {
 	b.AddChildFluent("li", "hello").
 		AddChildFluent("li", "world")
 	fmt.Println(b.String())
+
+	if err := b.AddChild("<script>", "alert('hi')"); err != nil {
+		fmt.Println(err)
+	}
+
+	menu := NewHTMLBuilder("ul")
+	menu.AddChild("li", "fish & chips")               // <- becomes fish &amp; chips
+	menu.AddRawChild("li", "<b>fish</b> &amp; chips") // <- left alone
+	fmt.Println(menu.String())
+
+	nested := NewHTMLBuilder("div")
+	nested.AddChild("p", "hello")
+	nested.root.elements[0].elements = append(nested.root.elements[0].elements,
+		HTMLElement{name: "em", text: "world"})
+	fmt.Print(nested.Render(RenderOptions{}))
+	fmt.Println(nested.Render(RenderOptions{Minify: true}))
 }
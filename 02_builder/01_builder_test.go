@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddChildRejectsInvalidTagNames(t *testing.T) {
+	b := NewHTMLBuilder("ul")
+	for _, name := range []string{"", "<script>", "li class", "a/b"} {
+		if err := b.AddChild(name, "text"); err == nil {
+			t.Errorf("AddChild(%q): expected an error", name)
+		}
+		if err := b.AddRawChild(name, "text"); err == nil {
+			t.Errorf("AddRawChild(%q): expected an error", name)
+		}
+	}
+	if len(b.root.elements) != 0 {
+		t.Fatalf("invalid children were added: %v", b.root.elements)
+	}
+	if err := b.AddChild("my-tag2", "text"); err != nil {
+		t.Fatalf("AddChild(my-tag2): %v", err)
+	}
+}
+
+func TestMustAddChildPanicsOnInvalidTagName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	NewHTMLBuilder("ul").AddChildFluent("li", "ok").AddChildFluent("<li>", "not ok")
+}
+
+func TestTextIsEscapedUnlessRaw(t *testing.T) {
+	b := NewHTMLBuilder("ul")
+	b.AddChild("li", `fish & chips <"a">`)
+	b.AddRawChild("li", "<b>fish</b>")
+
+	out := b.String()
+	if !strings.Contains(out, "fish &amp; chips &lt;&#34;a&#34;&gt;") {
+		t.Errorf("text was not escaped:\n%s", out)
+	}
+	if !strings.Contains(out, "<b>fish</b>") {
+		t.Errorf("raw text was escaped:\n%s", out)
+	}
+}
+
+func TestRenderPrettyAndMinified(t *testing.T) {
+	b := NewHTMLBuilder("div")
+	b.AddChild("p", "hello")
+	b.root.elements[0].elements = append(b.root.elements[0].elements,
+		HTMLElement{name: "em", text: "world"})
+
+	pretty := b.Render(RenderOptions{})
+	if pretty != b.String() {
+		t.Errorf("String() differs from the pretty rendering")
+	}
+	if !strings.Contains(pretty, "\n    <em>\n      world\n") {
+		t.Errorf("nested element is not indented:\n%s", pretty)
+	}
+
+	minified := b.Render(RenderOptions{Minify: true})
+	if strings.ContainsAny(minified, " \n") {
+		t.Errorf("minified output has whitespace: %q", minified)
+	}
+	if strings.Join(strings.Fields(pretty), "") != minified {
+		t.Errorf("minified %q is not the pretty output without whitespace", minified)
+	}
+}
@@ -6,7 +6,11 @@
 
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
 
 // We're going to work here with some same scenario, running
 // of ideas here.
@@ -87,6 +91,131 @@ func NewEmployeeFactoryStruct(position string, annualIncome int) *EmployeeFactor
 // But the first one is probably more idiomatic, and would go for that one.
 // And the second one is here if we need it for some reason.
 
+// Bonus:
+// There's one more approach that is very common in Go,
+// and that is the -> Functional Options pattern.
+// Instead of creating a factory for every single role, we have
+// a single factory function that takes the name, and then any
+// number of options that customize the employee.
+
+type EmployeeOption func(e *Employee)
+
+func WithPosition(position string) EmployeeOption {
+	return func(e *Employee) {
+		e.Position = position
+	}
+}
+
+func WithIncome(annualIncome int) EmployeeOption {
+	return func(e *Employee) {
+		e.AnnualIncome = annualIncome
+	}
+}
+
+// Whatever is not specified keeps its default value.
+
+const (
+	defaultPosition     = "dev"
+	defaultAnnualIncome = 175
+)
+
+func NewEmployee(name string, opts ...EmployeeOption) *Employee {
+	e := &Employee{name, defaultPosition, defaultAnnualIncome}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// <- The options are applied in order, so if the same option
+//	  is given twice, the last one wins.
+
+// And once we have a bunch of factories, we might want to
+// pick one by its name, say from a drop-down in some UI, which
+// also means we need to be able to list all of them.
+// So we can keep them in a registry.
+
+type FactoryRegistry[T any] struct {
+	producers map[string]func() T
+}
+
+func NewFactoryRegistry[T any]() *FactoryRegistry[T] {
+	return &FactoryRegistry[T]{map[string]func() T{}}
+}
+
+func (r *FactoryRegistry[T]) Register(name string, producer func() T) {
+	r.producers[name] = producer
+}
+
+func (r *FactoryRegistry[T]) Create(name string) (T, error) {
+	producer, ok := r.producers[name]
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("no factory registered under %q", name)
+	}
+	return producer(), nil
+}
+
+// <- Registering under the same name twice replaces the old factory.
+
+func (r *FactoryRegistry[T]) Names() []string {
+	names := make([]string, 0, len(r.producers))
+	for name := range r.producers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// <- Maps have no order, so we sort the names to get the same
+//	  list every time.
+
+// The factories we've made so far need the employee's name,
+// so every role registers a producer of a nameless employee,
+// and the name gets filled in once we know it.
+
+func NewRoleRegistry() *FactoryRegistry[*Employee] {
+	roles := NewFactoryRegistry[*Employee]()
+	roles.Register("dev", func() *Employee {
+		return NewEmployeeFactory("dev", 175)("")
+	})
+	roles.Register("manager", func() *Employee {
+		return NewEmployeeFactory("manager", 175000)("")
+	})
+	return roles
+}
+
+// Some things are expensive to make, like a connection, or
+// an employee we had to headhunt. For those we'd rather make one
+// the first time somebody asks for it, and then keep handing out
+// that very same one to whoever asks with the same key.
+
+type CachingFactory[K comparable, T any] struct {
+	mu      sync.Mutex
+	produce func(key K) T
+	cache   map[K]T
+}
+
+func NewCachingFactory[K comparable, T any](produce func(key K) T) *CachingFactory[K, T] {
+	return &CachingFactory[K, T]{produce: produce, cache: map[K]T{}}
+}
+
+func (f *CachingFactory[K, T]) Get(key K) T {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, ok := f.cache[key]; ok {
+		return t
+	}
+	t := f.produce(key)
+	f.cache[key] = t
+	return t
+}
+
+// <- We hold the lock while producing, so even if lots of goroutines
+//	  ask for the same key at once, it only ever gets produced once.
+//	  The price is that a slow producer holds up every other key as well.
+
 func main() {
 	// NewEmployee(1)
 	// e.Name
@@ -103,4 +232,37 @@ func main() {
 	bossFactory.AnnualIncome = 9000000000
 	bss := bossFactory.Create("Bob")
 	fmt.Println(bss)
+
+	fmt.Println(NewEmployee("Ada"))
+	fmt.Println(NewEmployee("Grace", WithPosition("admiral"), WithIncome(250000)))
+
+	roles := NewRoleRegistry()
+	fmt.Println("Roles:", roles.Names())
+	if e, err := roles.Create("manager"); err == nil {
+		e.Name = "Margaret"
+		fmt.Println(e)
+	}
+	if _, err := roles.Create("intern"); err != nil {
+		fmt.Println(err)
+	}
+
+	hires := NewCachingFactory(func(position string) *Employee {
+		fmt.Println("Headhunting a new", position)
+		return NewEmployee("", WithPosition(position), WithIncome(1000000))
+	})
+	ceos := make([]*Employee, 10)
+	var wg sync.WaitGroup
+	for i := range ceos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ceos[i] = hires.Get("CEO")
+		}()
+	}
+	wg.Wait()
+	same := true
+	for _, ceo := range ceos {
+		same = same && ceo == hires.Get("CEO")
+	}
+	fmt.Println("Always the same CEO:", same)
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewEmployeeOptions(t *testing.T) {
+	e := NewEmployee("Ada")
+	if *e != (Employee{"Ada", defaultPosition, defaultAnnualIncome}) {
+		t.Errorf("defaults: got %+v", *e)
+	}
+
+	e = NewEmployee("Grace", WithPosition("admiral"), WithIncome(1), WithIncome(2))
+	if *e != (Employee{"Grace", "admiral", 2}) {
+		t.Errorf("options: got %+v, the last option should win", *e)
+	}
+}
+
+func TestFactoryRegistry(t *testing.T) {
+	roles := NewRoleRegistry()
+	if names := roles.Names(); len(names) != 2 || names[0] != "dev" || names[1] != "manager" {
+		t.Fatalf("Names() = %v, want [dev manager]", names)
+	}
+
+	m1, err := roles.Create("manager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, _ := roles.Create("manager")
+	if m1 == m2 {
+		t.Error("Create returned the same instance twice")
+	}
+	if m1.Position != "manager" {
+		t.Errorf("Position = %q, want manager", m1.Position)
+	}
+
+	if _, err := roles.Create("intern"); err == nil {
+		t.Error("expected an error for an unknown name")
+	}
+
+	roles.Register("dev", func() *Employee { return NewEmployee("", WithPosition("senior dev")) })
+	if e, _ := roles.Create("dev"); e.Position != "senior dev" {
+		t.Errorf("re-registering did not replace the factory, got %q", e.Position)
+	}
+}
+
+func TestCachingFactoryProducesOncePerKey(t *testing.T) {
+	var produced atomic.Int32
+	hires := NewCachingFactory(func(position string) *Employee {
+		produced.Add(1)
+		return NewEmployee("", WithPosition(position))
+	})
+
+	ceos := make([]*Employee, 100)
+	var wg sync.WaitGroup
+	for i := range ceos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ceos[i] = hires.Get("CEO")
+		}()
+	}
+	wg.Wait()
+
+	if n := produced.Load(); n != 1 {
+		t.Fatalf("produced %d times, want 1", n)
+	}
+	for i, ceo := range ceos {
+		if ceo != ceos[0] {
+			t.Fatalf("caller %d got a different instance", i)
+		}
+	}
+	if hires.Get("CTO") == ceos[0] {
+		t.Fatal("a different key got the same instance")
+	}
+	if n := produced.Load(); n != 2 {
+		t.Fatalf("produced %d times, want 2", n)
+	}
+}
@@ -83,6 +83,43 @@ func (p *Person) DeepCopy() *Person {
 // Because the prototype design pattern is all about taking a pre-configured
 // object, like John there, making a copy and then customizing it like we do.
 
+// Bonus:
+// If we keep making people out of the same few pre-configured ones,
+// we might as well keep those around under a name, and let whoever
+// wants a new person say which one to start from and what to change.
+
+type PersonRegistry struct {
+	templates map[string]*Person
+}
+
+func NewPersonRegistry() *PersonRegistry {
+	return &PersonRegistry{templates: map[string]*Person{}}
+}
+
+// <- We keep a copy of the template, so whoever registered it
+//	  can't change it behind our back afterwards.
+
+func (r *PersonRegistry) Register(name string, template *Person) {
+	r.templates[name] = template.DeepCopy()
+}
+
+// Spawning is the prototype and a little bit of a builder.
+// We copy the template, and then the override gets to customize
+// the copy any way it likes before anybody else sees it.
+
+func (r *PersonRegistry) Spawn(name string, override func(p *Person)) (*Person, error) {
+	template, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("no template named %q", name)
+	}
+
+	p := template.DeepCopy()
+	if override != nil {
+		override(p)
+	}
+	return p, nil
+}
+
 func main() {
 	john := Person{
 		"John",
@@ -101,4 +138,21 @@ func main() {
 
 	fmt.Println(john, john.Address)
 	fmt.Println(jane, jane.Address)
+
+	registry := NewPersonRegistry()
+	registry.Register("london-base", &john)
+
+	alice, _ := registry.Spawn("london-base", func(p *Person) {
+		p.Name = "Alice"
+		p.Friends = append(p.Friends, "Bob")
+	})
+	bob, _ := registry.Spawn("london-base", func(p *Person) {
+		p.Name = "Bob"
+		p.Address.StreetAddress = "1 Abbey Road"
+	})
+	fmt.Println(alice, alice.Address)
+	fmt.Println(bob, bob.Address)
+
+	_, err := registry.Spawn("paris-base", nil)
+	fmt.Println(err)
 }
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func londonBase() *Person {
+	return &Person{"John", &Address{"123 London Road", "London", "UK"}, []string{"Chris"}}
+}
+
+func TestSpawnCopiesTheTemplate(t *testing.T) {
+	registry := NewPersonRegistry()
+	registry.Register("london-base", londonBase())
+
+	alice, err := registry.Spawn("london-base", func(p *Person) {
+		p.Name = "Alice"
+		p.Address.StreetAddress = "1 Abbey Road"
+		p.Friends = append(p.Friends, "Bob")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.Name != "Alice" || alice.Address.StreetAddress != "1 Abbey Road" || len(alice.Friends) != 2 {
+		t.Fatalf("override not applied: %+v %+v", alice, alice.Address)
+	}
+
+	plain, _ := registry.Spawn("london-base", nil)
+	if plain.Name != "John" || plain.Address.StreetAddress != "123 London Road" || len(plain.Friends) != 1 {
+		t.Fatalf("template changed by a spawn: %+v %+v", plain, plain.Address)
+	}
+	if plain.Address == alice.Address {
+		t.Fatal("spawned people share an address")
+	}
+}
+
+func TestRegisterCopiesTheTemplate(t *testing.T) {
+	registry := NewPersonRegistry()
+	john := londonBase()
+	registry.Register("london-base", john)
+	john.Address.City = "Paris"
+
+	p, _ := registry.Spawn("london-base", nil)
+	if p.Address.City != "London" {
+		t.Fatalf("City = %q, the registered template changed behind our back", p.Address.City)
+	}
+}
+
+func TestSpawnUnknownTemplate(t *testing.T) {
+	if _, err := NewPersonRegistry().Spawn("paris-base", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+}
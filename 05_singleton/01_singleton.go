@@ -18,10 +18,13 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type singletonDatabase struct {
@@ -99,25 +102,161 @@ func readData(path string) (map[string]int, error) {
 	return result, nil
 }
 
-func GetSingletonDB() *singletonDatabase {
+var instanceErr error
+
+func GetSingletonDB() (*singletonDatabase, error) {
 	once.Do(func() {
-		db := singletonDatabase{}
+		initCount.Add(1)
 		caps, err := readData(".\\capitals.txt")
-		if err == nil {
-			db.capitals = caps
+		if err != nil {
+			instanceErr = err
+			return
 		}
-		instance = &db
+		instance = &singletonDatabase{capitals: caps}
 	})
-	return instance
+	return instance, instanceErr
 }
 
 // <- Here we keep returning the instance pointer if
 // 	  there are several callers who are attempting to get data f
 //    rom this db.
 
+// And if reading the file failed, we keep returning that error instead,
+// so nobody ends up silently asking an empty database for populations.
+// Since sync.Once runs only once, the failure is final, a missing
+// database file is not something that fixes itself while we're running.
+
+// But we don't have to take "only once" on faith. We can count how many
+// times the initialization actually ran, and then throw a whole bunch
+// of goroutines at GetSingletonDB() and see for ourselves.
+
+var initCount atomic.Int32
+
+func InitCount() int {
+	return int(initCount.Load())
+}
+
+// The flip side of "only once" is that tests suffer.
+// The first test to ask for the database decides what every other
+// test is going to see, including a failure to load it.
+// So the tests, next door in 01_singleton_test.go, get a way
+// to forget the instance and start from scratch, one which
+// the rest of the program doesn't even know exists.
+
+// Bonus:
+// Let's take a look at another singleton, a configuration,
+// where loading can fail, not just because the file is missing,
+// but because the contents are simply wrong, and where we'd
+// like to be able to try again once they've been fixed.
+
+type config struct {
+	values map[string]string
+}
+
+func (c *config) Get(key string) string {
+	return c.values[key]
+}
+
+// These are the keys without which the configuration makes no sense.
+
+var requiredKeys = []string{"host", "port"}
+
+// And this is where the configuration comes from.
+// It's a variable so that we can point it somewhere else.
+
+var configSource = func() (io.Reader, error) {
+	ex, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(filepath.Join(filepath.Dir(ex), "config.txt"))
+}
+
+func readConfig(r io.Reader) (*config, error) {
+	c := config{map[string]string{}}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), "=")
+		if ok {
+			c.values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, key := range requiredKeys {
+		if len(c.values[key]) == 0 {
+			return nil, fmt.Errorf("config: missing required key %q", key)
+		}
+	}
+	return &c, nil
+}
+
+// Now, we can't use sync.Once here, because once is once,
+// even if the function we gave it failed.
+// Instead we guard the instance with a mutex and only store it
+// when loading actually succeeded, so the next caller gets to try again.
+
+var configInstance *config
+var configMu sync.Mutex
+
+func GetConfig() (*config, error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	if configInstance != nil {
+		return configInstance, nil
+	}
+
+	r, err := configSource()
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	cfg, err := readConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	configInstance = cfg
+	return configInstance, nil
+}
+
+// <- Still lazy, still thread safe, but failures are not cached.
+
 func main() {
-	db := GetSingletonDB()
-	pop := db.GetPopulation("Seoul")
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			GetSingletonDB()
+		}()
+	}
+	wg.Wait()
+	fmt.Println("Initialized", InitCount(), "time(s) for 100 callers")
 
-	fmt.Println("Population of Seoul = ", pop)
+	db, err := GetSingletonDB()
+	if err != nil {
+		fmt.Println("Could not load the database:", err)
+	} else {
+		pop := db.GetPopulation("Seoul")
+		fmt.Println("Population of Seoul = ", pop)
+	}
+
+	configSource = func() (io.Reader, error) {
+		return strings.NewReader("host = localhost"), nil
+	}
+	_, err = GetConfig()
+	fmt.Println("First attempt:", err)
+
+	configSource = func() (io.Reader, error) {
+		return strings.NewReader("host = localhost\nport = 8080"), nil
+	}
+	cfg, err := GetConfig()
+	fmt.Println("Second attempt:", cfg.Get("host")+":"+cfg.Get("port"), err)
 }
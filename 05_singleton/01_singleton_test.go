@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func resetSingletonForTest() {
+	instance = nil
+	instanceErr = nil
+	once = sync.Once{}
+}
+
+// <- Not thread safe at all, and not meant to be.
+//	  It's only for the moments in between tests, when nobody
+//	  else is holding on to the database.
+
+func TestGetSingletonDBInitializesOnce(t *testing.T) {
+	resetSingletonForTest()
+	before := InitCount()
+
+	var wg sync.WaitGroup
+	results := make([]*singletonDatabase, 100)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = GetSingletonDB()
+		}()
+	}
+	wg.Wait()
+
+	if n := InitCount() - before; n != 1 {
+		t.Fatalf("initialized %d times for 100 callers, want 1", n)
+	}
+	for i, db := range results {
+		if db != results[0] {
+			t.Fatalf("caller %d got a different instance", i)
+		}
+	}
+}
+
+func TestResetSingletonForTest(t *testing.T) {
+	resetSingletonForTest()
+	before := InitCount()
+	GetSingletonDB()
+	GetSingletonDB()
+
+	resetSingletonForTest()
+	GetSingletonDB()
+
+	if n := InitCount() - before; n != 2 {
+		t.Fatalf("initialized %d times around a reset, want 2", n)
+	}
+}
+
+func TestGetSingletonDBReturnsLoadError(t *testing.T) {
+	resetSingletonForTest()
+	// there's no capitals.txt next to the test binary
+	db, err := GetSingletonDB()
+	if err == nil || db != nil {
+		t.Fatalf("got %v, %v, want the load error", db, err)
+	}
+	if _, again := GetSingletonDB(); again != err {
+		t.Fatalf("second call got %v, want the same error", again)
+	}
+}
+
+func useConfig(t *testing.T, contents ...string) {
+	calls := 0
+	old := configSource
+	configSource = func() (io.Reader, error) {
+		if calls >= len(contents) {
+			return nil, errors.New("no more config")
+		}
+		calls++
+		return strings.NewReader(contents[calls-1]), nil
+	}
+	configInstance = nil
+	t.Cleanup(func() {
+		configSource = old
+		configInstance = nil
+	})
+}
+
+func TestGetConfigRetriesAfterInvalidConfig(t *testing.T) {
+	useConfig(t, "host=localhost\n", "host = localhost\nport = 8080\n")
+
+	if _, err := GetConfig(); err == nil || !strings.Contains(err.Error(), `"port"`) {
+		t.Fatalf("got %v, want the missing port", err)
+	}
+	cfg, err := GetConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Get("host") != "localhost" || cfg.Get("port") != "8080" {
+		t.Fatalf("got %v", cfg.values)
+	}
+	if again, err := GetConfig(); err != nil || again != cfg {
+		t.Fatalf("a loaded config is not reused: %v, %v", again, err)
+	}
+}
+
+func TestGetConfigConcurrently(t *testing.T) {
+	useConfig(t, "host=a\nport=1\n")
+
+	configs := make([]*config, 50)
+	var wg sync.WaitGroup
+	for i := range configs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			configs[i], _ = GetConfig()
+		}()
+	}
+	wg.Wait()
+	for i, cfg := range configs {
+		if cfg == nil || cfg != configs[0] {
+			t.Fatalf("caller %d got %v", i, cfg)
+		}
+	}
+}
@@ -9,6 +9,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"strings"
 )
 
@@ -124,16 +125,23 @@ func (v vectorToRasterAdapter) GetPoints() []Point {
 // it private, so we need some sort of factory function to actually construct
 // a new VectorToRaster adapter.
 
-func VectorToRaster(vi *VectorImage) RasterImage {
+func VectorToRaster(vi *VectorImage, strategy ...RasterizeStrategy) RasterImage {
 	adapter := vectorToRasterAdapter{}
 
+	rasterize := ThinLine
+	if len(strategy) > 0 {
+		rasterize = strategy[0]
+	}
+
 	for _, line := range vi.Lines {
-		adapter.addLine(line)
+		adapter.addLine(line, rasterize)
 	}
 
 	return &adapter
 }
 
+// <- The strategy is optional, more on it at the end.
+
 // Now we need a to take a line and decomposed it and set up a bunch of points.
 // Before that we need minimax function.
 
@@ -146,7 +154,20 @@ func minmax(a, b int) (int, int) {
 
 // ↑↑↑ This is a sad function. No ternary operator!
 
-func (a *vectorToRasterAdapter) addLine(line Line) {
+func (a *vectorToRasterAdapter) addLine(line Line, rasterize RasterizeStrategy) {
+	a.points = append(a.points, rasterize(line)...)
+
+	fmt.Println("we have", len(a.points), "points")
+}
+
+// The actual work of turning a line into points is
+// done by whichever strategy we've been given.
+// By default, every line is a single point thick.
+
+type RasterizeStrategy func(line Line) []Point
+
+func ThinLine(line Line) []Point {
+	var points []Point
 	left, right := minmax(line.X1, line.X2)
 	top, bottom := minmax(line.Y1, line.Y2)
 	dx := right - left
@@ -154,15 +175,15 @@ func (a *vectorToRasterAdapter) addLine(line Line) {
 
 	if dx == 0 {
 		for y := top; y <= bottom; y++ {
-			a.points = append(a.points, Point{left, y})
+			points = append(points, Point{left, y})
 		}
 	} else if dy == 0 {
 		for x := left; x <= right; x++ {
-			a.points = append(a.points, Point{x, top})
+			points = append(points, Point{x, top})
 		}
 	}
 
-	fmt.Println("we have", len(a.points), "points")
+	return points
 }
 
 // So this is how we build and adapter basically, so we've just
@@ -170,8 +191,236 @@ func (a *vectorToRasterAdapter) addLine(line Line) {
 // adapted it to a completely different API [RasterImages] that only
 // wants to deal with points.
 
+// Bonus:
+// Since the adapter doesn't rasterize the lines itself,
+// the very same vector image can be adapted in different ways.
+// For example, we can make every line two points thick,
+// by drawing it once more, one point to the right of vertical
+// lines and one point below everything else.
+
+func ThickLine(line Line) []Point {
+	points := ThinLine(line)
+	for _, p := range ThinLine(line) {
+		if line.X1 == line.X2 {
+			points = append(points, Point{p.X + 1, p.Y})
+		} else {
+			points = append(points, Point{p.X, p.Y + 1})
+		}
+	}
+	return points
+}
+
+// <- Raster images are not the only thing we can adapt to.
+// Quite often we need to hand our images over to somebody else,
+// and the easiest way of doing that is some standard format, like SVG.
+// And lucky us, SVG thinks in lines just like we do.
+
+func VectorToSVG(vi *VectorImage) string {
+	maxX, maxY := 0, 0
+	for _, line := range vi.Lines {
+		_, right := minmax(line.X1, line.X2)
+		_, bottom := minmax(line.Y1, line.Y2)
+		if right > maxX {
+			maxX = right
+		}
+		if bottom > maxY {
+			maxY = bottom
+		}
+	}
+
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf(
+		"<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\">\n",
+		maxX+1, maxY+1))
+	for _, line := range vi.Lines {
+		b.WriteString(fmt.Sprintf(
+			"  <line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"black\" />\n",
+			line.X1, line.Y1, line.X2, line.Y2))
+	}
+	b.WriteString("</svg>\n")
+
+	return b.String()
+}
+
+// <- The lines are written in the order they're given,
+//	  so the same image always gives us the same document.
+
+// And adapters can go the other way around as well.
+// DrawPoints turns points into a grid of characters, but if
+// somebody hands us a grid, say some ASCII art they've edited,
+// we'd like to turn it back into points.
+
+type gridToRasterAdapter struct {
+	points []Point
+}
+
+func (g gridToRasterAdapter) GetPoints() []Point {
+	return g.points
+}
+
+func GridToRaster(rows []string, mark rune) RasterImage {
+	adapter := gridToRasterAdapter{}
+	for y, row := range rows {
+		for x, r := range []rune(row) {
+			if r == mark {
+				adapter.points = append(adapter.points, Point{x, y})
+			}
+		}
+	}
+	return &adapter
+}
+
+// <- Whatever we get back is a raster image like any other,
+//	  so it can go straight back into DrawPoints.
+
+// Our adapter turns the whole image into points the moment it's
+// made, and keeps all of them around. For a really big image that's
+// a lot of memory, especially if all we want is to go over the points once.
+
+// So here's an adapter which doesn't do any work up front,
+// it just remembers the lines, and hands out the points one at a time
+// over a channel, rasterizing one line at a time as it goes.
+
+type StreamingRasterImage interface {
+	RasterImage
+	PointStream() <-chan Point
+}
+
+type streamingVectorAdapter struct {
+	lines     []Line
+	rasterize RasterizeStrategy
+}
+
+func VectorToRasterStream(vi *VectorImage, strategy ...RasterizeStrategy) StreamingRasterImage {
+	adapter := &streamingVectorAdapter{lines: vi.Lines, rasterize: ThinLine}
+	if len(strategy) > 0 {
+		adapter.rasterize = strategy[0]
+	}
+	return adapter
+}
+
+func (s *streamingVectorAdapter) PointStream() <-chan Point {
+	ch := make(chan Point)
+	go func() {
+		defer close(ch)
+		for _, line := range s.lines {
+			for _, p := range s.rasterize(line) {
+				ch <- p
+			}
+		}
+	}()
+	return ch
+}
+
+// <- The channel is closed once every line has been sent, so we
+//	  can simply range over it. Whoever starts reading has to read
+//	  until the end though, otherwise the goroutine is stuck forever.
+
+// It's still a raster image, for whoever really needs a slice.
+
+func (s *streamingVectorAdapter) GetPoints() []Point {
+	var points []Point
+	for p := range s.PointStream() {
+		points = append(points, p)
+	}
+	return points
+}
+
+// And drawing works straight off the stream. We don't know how big
+// the image is going to be, so the grid grows as the points come in.
+
+func DrawPointStream(points <-chan Point) string {
+	var data [][]rune
+	for point := range points {
+		for len(data) <= point.Y {
+			data = append(data, nil)
+		}
+		for len(data[point.Y]) <= point.X {
+			data[point.Y] = append(data[point.Y], ' ')
+		}
+		data[point.Y][point.X] = '*'
+	}
+
+	b := strings.Builder{}
+	for _, line := range data {
+		b.WriteString(string(line))
+		b.WriteRune('\n')
+	}
+	return b.String()
+}
+
+// <- Rows are only as long as their last point,
+//	  which looks just the same on the console.
+
+// Not every vector API works in whole numbers either.
+// Suppose another one gives us lines in floating point coordinates,
+// say in centimeters, while our raster still only knows whole points.
+
+type FloatLine struct {
+	X1, Y1, X2, Y2 float64
+}
+
+type FloatVectorImage struct {
+	Lines []FloatLine
+}
+
+// So first, we scale every coordinate, to decide how many points
+// there are to a centimeter, and then we round it to the nearest point.
+// What comes out is an ordinary vector image, which the adapter
+// we already have knows how to deal with.
+
+func FloatToVector(fi *FloatVectorImage, scale float64) *VectorImage {
+	round := func(f float64) int {
+		return int(math.Round(f * scale))
+	}
+
+	vi := &VectorImage{}
+	for _, l := range fi.Lines {
+		vi.Lines = append(vi.Lines, Line{round(l.X1), round(l.Y1), round(l.X2), round(l.Y2)})
+	}
+	return vi
+}
+
+func FloatVectorToRaster(fi *FloatVectorImage, scale float64, strategy ...RasterizeStrategy) RasterImage {
+	return VectorToRaster(FloatToVector(fi, scale), strategy...)
+}
+
+// <- Two adapters chained together, floats to ints, and lines to points.
+//	  Rounding happens once per coordinate, so both ends of a line
+//	  land on the same points as the lines they meet.
+
 func main() {
 	rc := NewRectangle(6, 4)
 	a := VectorToRaster(rc)
 	fmt.Print(DrawPoints(a))
+
+	thick := VectorToRaster(rc, ThickLine)
+	fmt.Print(DrawPoints(thick))
+	fmt.Println(len(thick.GetPoints()), "thick points vs", len(a.GetPoints()), "thin ones")
+
+	fmt.Print(VectorToSVG(rc))
+
+	grid := GridToRaster([]string{
+		"*  *",
+		" ** ",
+		"*  *",
+	}, '*')
+	fmt.Print(DrawPoints(grid))
+
+	stream := VectorToRasterStream(rc)
+	count := 0
+	for range stream.PointStream() {
+		count++
+	}
+	fmt.Println("Streamed", count, "points")
+	fmt.Print(DrawPointStream(stream.PointStream()))
+
+	frc := &FloatVectorImage{[]FloatLine{
+		{0, 0, 2.4, 0},
+		{0, 0, 0, 1.2},
+		{2.4, 0, 2.4, 1.2},
+		{0, 1.2, 2.4, 1.2},
+	}}
+	fmt.Println("Scaled lines:", FloatToVector(frc, 2).Lines)
+	fmt.Print(DrawPoints(FloatVectorToRaster(frc, 2)))
 }
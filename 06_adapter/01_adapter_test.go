@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVectorToSVG(t *testing.T) {
+	svg := VectorToSVG(NewRectangle(6, 4))
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="6" height="4">`) {
+		t.Errorf("wrong header:\n%s", svg)
+	}
+	if n := strings.Count(svg, "<line "); n != 4 {
+		t.Errorf("%d lines, want 4", n)
+	}
+	if !strings.Contains(svg, `<line x1="5" y1="0" x2="5" y2="3" stroke="black" />`) {
+		t.Errorf("right edge missing:\n%s", svg)
+	}
+	if svg != VectorToSVG(NewRectangle(6, 4)) {
+		t.Error("the same image gave a different document")
+	}
+}
+
+func TestGridToRasterRoundTrip(t *testing.T) {
+	rows := []string{
+		"*  *",
+		" ** ",
+		"*  *",
+	}
+	grid := GridToRaster(rows, '*')
+	if n := len(grid.GetPoints()); n != 6 {
+		t.Fatalf("%d points, want 6", n)
+	}
+	if got := DrawPoints(grid); got != strings.Join(rows, "\n")+"\n" {
+		t.Fatalf("round trip gave:\n%s", got)
+	}
+}
+
+func TestRasterizeStrategies(t *testing.T) {
+	rc := NewRectangle(6, 4)
+	thin := VectorToRaster(rc).GetPoints()
+	thick := VectorToRaster(rc, ThickLine).GetPoints()
+	if len(thick) != 2*len(thin) {
+		t.Fatalf("%d thick points for %d thin ones", len(thick), len(thin))
+	}
+	if !reflect.DeepEqual(VectorToRaster(rc, ThinLine).GetPoints(), thin) {
+		t.Fatal("ThinLine is not the default strategy")
+	}
+}
+
+func TestStreamingMatchesSlice(t *testing.T) {
+	rc := NewRectangle(6, 4)
+	stream := VectorToRasterStream(rc, ThickLine)
+
+	var streamed []Point
+	for p := range stream.PointStream() {
+		streamed = append(streamed, p)
+	}
+	want := VectorToRaster(rc, ThickLine).GetPoints()
+	if !reflect.DeepEqual(streamed, want) {
+		t.Fatalf("streamed %v, want %v", streamed, want)
+	}
+	if !reflect.DeepEqual(stream.GetPoints(), want) {
+		t.Fatal("GetPoints differs from the stream")
+	}
+	if got := DrawPointStream(VectorToRasterStream(rc).PointStream()); got != DrawPoints(VectorToRaster(rc)) {
+		t.Fatalf("drawing the stream gave:\n%s", got)
+	}
+}
+
+func TestFloatToVector(t *testing.T) {
+	fi := &FloatVectorImage{[]FloatLine{{0, 0, 2.4, 0}, {0.26, 1.2, 2.4, 1.24}}}
+	got := FloatToVector(fi, 2).Lines
+	want := []Line{{0, 0, 5, 0}, {1, 2, 5, 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if n := len(FloatVectorToRaster(fi, 2).GetPoints()); n != 11 {
+		t.Fatalf("%d points, want 11", n)
+	}
+}
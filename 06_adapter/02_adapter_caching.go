@@ -150,12 +150,12 @@ var pointCache = map[[16]byte][]Point{}
 // Now we need to change our addLine() so that it doesn't add
 // those points if they've already been generated.
 
-func (a *vectorToRasterAdapter) addLineCache(line Line) {
-	hash := func(obj interface{}) [16]byte {
-		bytes, _ := json.Marshal(obj)
-		return md5.Sum(bytes)
-	}
+func hash(obj interface{}) [16]byte {
+	bytes, _ := json.Marshal(obj)
+	return md5.Sum(bytes)
+}
 
+func (a *vectorToRasterAdapter) addLineCache(line Line) {
 	h := hash(line)
 	if pts, ok := pointCache[h]; ok {
 		for _, pt := range pts {
@@ -164,6 +164,8 @@ func (a *vectorToRasterAdapter) addLineCache(line Line) {
 		return
 	}
 
+	pointComputations++
+	var points []Point
 	left, right := minmax(line.X1, line.X2)
 	top, bottom := minmax(line.Y1, line.Y2)
 	dx := right - left
@@ -171,30 +173,52 @@ func (a *vectorToRasterAdapter) addLineCache(line Line) {
 
 	if dx == 0 {
 		for y := top; y <= bottom; y++ {
-			a.points = append(a.points, Point{left, y})
+			points = append(points, Point{left, y})
 		}
 	} else if dy == 0 {
 		for x := left; x <= right; x++ {
-			a.points = append(a.points, Point{x, top})
+			points = append(points, Point{x, top})
 		}
 	}
 
-	pointCache[h] = a.points
+	pointCache[h] = points
+	a.points = append(a.points, points...)
 	fmt.Println("we have", len(a.points), "points")
 }
 
+// <- Only the points of this very line go into the cache,
+//	  not everything the adapter has collected so far.
+
+// And just so we can see how much work is being done,
+// we count every time the points actually get computed.
+
+var pointComputations = 0
+
 // Now use this in our adapter.
 
 func VectorToRasterCached(vi *VectorImage) RasterImage {
+	h := hash(vi.Lines)
+	if adapter, ok := imageCache[h]; ok {
+		return adapter
+	}
+
 	adapter := vectorToRasterAdapter{}
 
 	for _, line := range vi.Lines {
 		adapter.addLineCache(line)
 	}
 
+	imageCache[h] = &adapter
 	return &adapter
 }
 
+// <- Even with every line cached, we'd still be making a new adapter
+//	  and copying every single point into it each time. But if the whole
+//	  image is the same as one we've seen before, the whole adapter is
+//	  the same as well, so we can cache that too, keyed by all of the lines.
+
+var imageCache = map[[16]byte]*vectorToRasterAdapter{}
+
 // And we can improve the situation even further by
 // not storing those extra points by using Point pointers instead.
 
@@ -207,6 +231,8 @@ func main() {
 	// a := VectorToRaster(rc)
 	// _ = VectorToRaster(rc)
 	a := VectorToRasterCached(rc)
+	before := pointComputations
 	_ = VectorToRasterCached(rc)
 	fmt.Print(DrawPoints(a))
+	fmt.Println("Recomputed", pointComputations-before, "lines the second time")
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVectorToRasterCachedReusesWholeImage(t *testing.T) {
+	rc := NewRectangle(7, 5)
+	a := VectorToRasterCached(rc)
+	before := pointComputations
+	b := VectorToRasterCached(NewRectangle(7, 5))
+
+	if a != b {
+		t.Error("the same image got a different adapter")
+	}
+	if n := pointComputations - before; n != 0 {
+		t.Errorf("recomputed %d lines", n)
+	}
+	if !reflect.DeepEqual(a.GetPoints(), VectorToRaster(rc).GetPoints()) {
+		t.Error("cached points differ from uncached ones")
+	}
+}
+
+func TestLineCacheKeepsOnlyItsOwnPoints(t *testing.T) {
+	VectorToRasterCached(NewRectangle(8, 3))
+
+	top := Line{0, 0, 7, 0}
+	if pts := pointCache[hash(top)]; len(pts) != 8 {
+		t.Fatalf("cached %d points for an 8 point line", len(pts))
+	}
+
+	// a different rectangle sharing the top line only computes the rest
+	before := pointComputations
+	VectorToRasterCached(NewRectangle(8, 6))
+	if n := pointComputations - before; n != 3 {
+		t.Fatalf("computed %d lines, want 3", n)
+	}
+}
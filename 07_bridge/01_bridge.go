@@ -26,17 +26,34 @@
 
 package main
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
 
 // Instead of having shapes specialized for different renderers
 // we would take those and maybe introduce an interface like:
 
 type Renderer interface {
-	RenderCircle(radius float32)
+	RenderCircle(radius float32) error
 }
 
 // <- and we could have RenderSquare or RenderTriangle ...
 
+// Whatever the format, nobody can draw a circle with a negative
+// radius, or a radius which isn't even a number, so every renderer
+// checks what it's given and reports back if it can't draw it.
+
+func validateDimension(name string, value float32) error {
+	v := float64(value)
+	if math.IsNaN(v) || v <= 0 {
+		return fmt.Errorf("invalid %s: %v, must be a positive number", name, value)
+	}
+	return nil
+}
+
 // Now we can define types which take care of the rendering
 // in a different format.
 
@@ -47,8 +64,12 @@ type VectorRenderer struct {
 
 // And then we would have a function for actually rendering our type:
 
-func (v *VectorRenderer) RenderCircle(radius float32) {
+func (v *VectorRenderer) RenderCircle(radius float32) error {
+	if err := validateDimension("radius", radius); err != nil {
+		return err
+	}
 	fmt.Println("Drawing a Circle of radius: ", radius)
+	return nil
 }
 
 // But similarly, we can have a Raster renderer which also knows how
@@ -59,8 +80,12 @@ type RasterRenderer struct {
 	// ...
 }
 
-func (r *RasterRenderer) RenderCircle(radius float32) {
+func (r *RasterRenderer) RenderCircle(radius float32) error {
+	if err := validateDimension("radius", radius); err != nil {
+		return err
+	}
 	fmt.Println("Drawing pixels for Circle of radius: ", radius)
+	return nil
 }
 
 // Now we can define the circle.
@@ -83,8 +108,8 @@ func NewCircle(renderer Renderer, radius float32) *Circle {
 
 // And then we need a function for drawing a circle:
 
-func (c *Circle) Draw() {
-	c.renderer.RenderCircle(c.radius)
+func (c *Circle) Draw() error {
+	return c.renderer.RenderCircle(c.radius)
 }
 
 // ↑↑↑ This is precisely where we would do these sort of specific
@@ -122,6 +147,110 @@ func (c *Circle) Resize(factor float32) {
 // we can see that this is somewhat better then allowing a complexity explosion
 // of an infinite number of types.
 
+// Bonus:
+// Since the renderer is just an interface, nothing stops us
+// from putting something in between the shape and the actual
+// renderer. For example, if drawing the very same circle over
+// and over again is expensive, we can remember what has already
+// been rendered.
+
+type circleKey struct {
+	radius float32
+}
+
+// <- Shape parameters are the key, the renderer itself is
+//	  fixed per cache, so the pair is covered.
+
+type CachingRenderer struct {
+	renderer Renderer
+	rendered map[circleKey]bool
+}
+
+func NewCachingRenderer(renderer Renderer) *CachingRenderer {
+	return &CachingRenderer{
+		renderer: renderer,
+		rendered: map[circleKey]bool{},
+	}
+}
+
+// Only on a miss do we actually go to the underlying renderer.
+
+func (c *CachingRenderer) RenderCircle(radius float32) error {
+	key := circleKey{radius}
+	if c.rendered[key] {
+		return nil
+	}
+	if err := c.renderer.RenderCircle(radius); err != nil {
+		return err
+	}
+	c.rendered[key] = true
+	return nil
+}
+
+// <- A failed render is not remembered, so it fails every time.
+
+// And in the very same way, a renderer can stand in for several
+// renderers at once, so a single Draw() gives us both the vector
+// and the raster output.
+
+type MultiRenderer struct {
+	renderers []Renderer
+}
+
+func NewMultiRenderer(renderers ...Renderer) *MultiRenderer {
+	return &MultiRenderer{renderers}
+}
+
+func (m *MultiRenderer) RenderCircle(radius float32) error {
+	var errs []error
+	for _, r := range m.renderers {
+		errs = append(errs, r.RenderCircle(radius))
+	}
+	return errors.Join(errs...)
+}
+
+// <- The circle has no idea that it's being drawn twice.
+//	  If some of the renderers fail, we still give every one
+//	  of them a go, and report all of the failures together.
+
+// When debugging, it's handy to know which renderer a shape
+// is actually bound to. Renderers can tell us their name,
+// but it's optional, so we don't force it onto the Renderer interface.
+
+type Named interface {
+	Name() string
+}
+
+func (v *VectorRenderer) Name() string { return "VectorRenderer" }
+func (r *RasterRenderer) Name() string { return "RasterRenderer" }
+
+// Renderers that don't have a name still have a type.
+
+func rendererName(r Renderer) string {
+	if n, ok := r.(Named); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", r)
+}
+
+// <- And the renderers that wrap other renderers can say so.
+
+func (c *CachingRenderer) Name() string {
+	return "CachingRenderer(" + rendererName(c.renderer) + ")"
+}
+
+func (m *MultiRenderer) Name() string {
+	names := make([]string, len(m.renderers))
+	for i, r := range m.renderers {
+		names[i] = rendererName(r)
+	}
+	return "MultiRenderer(" + strings.Join(names, ", ") + ")"
+}
+
+func (c *Circle) RendererName() string {
+	return rendererName(c.renderer)
+}
+
 func main() {
 	// raster := RasterRenderer{}
 	vector := VectorRenderer{}
@@ -129,4 +258,20 @@ func main() {
 	circle.Draw()
 	circle.Resize(2)
 	circle.Draw()
+
+	cached := NewCircle(NewCachingRenderer(&vector), 5)
+	cached.Draw()
+	cached.Draw() // <- the vector renderer isn't called again
+
+	both := NewCircle(NewMultiRenderer(&vector, &RasterRenderer{}), 3)
+	both.Draw()
+
+	fmt.Println("Bound to", NewCircle(&RasterRenderer{}, 1).RendererName())
+	fmt.Println("Bound to", both.RendererName())
+
+	for _, radius := range []float32{1, -1, float32(math.NaN())} {
+		if err := NewCircle(&vector, radius).Draw(); err != nil {
+			fmt.Println("Error:", err)
+		}
+	}
 }
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// A renderer which only remembers what it was asked to draw.
+
+type recordingRenderer struct {
+	radii []float32
+	err   error
+}
+
+func (r *recordingRenderer) RenderCircle(radius float32) error {
+	r.radii = append(r.radii, radius)
+	return r.err
+}
+
+func TestCachingRendererRendersOnce(t *testing.T) {
+	rec := &recordingRenderer{}
+	circle := NewCircle(NewCachingRenderer(rec), 5)
+	circle.Draw()
+	circle.Draw()
+	circle.Resize(2)
+	circle.Draw()
+
+	if len(rec.radii) != 2 || rec.radii[0] != 5 || rec.radii[1] != 10 {
+		t.Fatalf("rendered %v, want [5 10]", rec.radii)
+	}
+}
+
+func TestCachingRendererForgetsFailures(t *testing.T) {
+	rec := &recordingRenderer{err: errors.New("out of ink")}
+	circle := NewCircle(NewCachingRenderer(rec), 5)
+	circle.Draw()
+	if err := circle.Draw(); err == nil {
+		t.Fatal("a failed render was cached")
+	}
+	if len(rec.radii) != 2 {
+		t.Fatalf("rendered %d times, want 2", len(rec.radii))
+	}
+}
+
+func TestMultiRendererRendersToAll(t *testing.T) {
+	ok := &recordingRenderer{}
+	first := errors.New("first")
+	second := errors.New("second")
+	failing := &recordingRenderer{err: first}
+	alsoFailing := &recordingRenderer{err: second}
+
+	err := NewCircle(NewMultiRenderer(failing, ok, alsoFailing), 3).Draw()
+	if !errors.Is(err, first) || !errors.Is(err, second) {
+		t.Fatalf("got %v, want both failures", err)
+	}
+	if len(ok.radii) != 1 || len(alsoFailing.radii) != 1 {
+		t.Fatal("a failure stopped the other renderers")
+	}
+}
+
+func TestRenderersValidateDimensions(t *testing.T) {
+	for _, r := range []Renderer{&VectorRenderer{}, &RasterRenderer{}} {
+		for _, radius := range []float32{0, -1, float32(math.NaN())} {
+			if err := NewCircle(r, radius).Draw(); err == nil {
+				t.Errorf("%s accepted radius %v", rendererName(r), radius)
+			}
+		}
+		if err := NewCircle(r, 1).Draw(); err != nil {
+			t.Errorf("%s: %v", rendererName(r), err)
+		}
+	}
+}
+
+func TestRendererName(t *testing.T) {
+	tests := []struct {
+		renderer Renderer
+		want     string
+	}{
+		{&VectorRenderer{}, "VectorRenderer"},
+		{&recordingRenderer{}, "*main.recordingRenderer"},
+		{NewCachingRenderer(&RasterRenderer{}), "CachingRenderer(RasterRenderer)"},
+		{
+			NewMultiRenderer(&VectorRenderer{}, NewCachingRenderer(&RasterRenderer{})),
+			"MultiRenderer(VectorRenderer, CachingRenderer(RasterRenderer))",
+		},
+	}
+	for _, tt := range tests {
+		if got := NewCircle(tt.renderer, 1).RendererName(); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -11,7 +11,13 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"hash"
+	"math"
 	"strings"
 )
 
@@ -20,10 +26,15 @@ import (
 // that is a combination of different objects [children].
 
 type GraphicObject struct {
-	Name, Color string
-	Children    []GraphicObject
+	Name     string          `json:"name"`
+	Color    string          `json:"color,omitempty"`
+	Size     float32         `json:"size,omitempty"`
+	Children []GraphicObject `json:"children,omitempty"`
 }
 
+// <- The tags are there so that we can save our drawing
+//	  as JSON and load it back, more on that at the end.
+
 // Now we need to print this, and printing this to a console
 // would be completely different depending on whether it's just
 // a single object or whether it's a graphic object container with
@@ -58,11 +69,11 @@ func (g *GraphicObject) print(sb *strings.Builder, depth int) {
 // Let's say we want to play with some squares and circles:
 
 func NewCircle(color string) *GraphicObject {
-	return &GraphicObject{"Circle", color, nil}
+	return &GraphicObject{Name: "Circle", Color: color}
 }
 
 func NewSquare(color string) *GraphicObject {
-	return &GraphicObject{"Square", color, nil}
+	return &GraphicObject{Name: "Square", Color: color}
 }
 
 // With all of this we can now setup a scenario where we have
@@ -88,16 +99,292 @@ func NewSquare(color string) *GraphicObject {
 // which don't really care, because all they do is they perform
 // the appropriate check.
 
+// Bonus:
+// The composite also cooperates nicely with the Visitor.
+// Instead of adding yet another recursive method to the
+// GraphicObject every time we need a new operation, we let
+// the object walk itself and hand every node to a visitor.
+
+type GraphicVisitor interface {
+	VisitObject(g *GraphicObject)
+}
+
+func (g *GraphicObject) Accept(v GraphicVisitor) {
+	v.VisitObject(g)
+	for i := range g.Children {
+		g.Children[i].Accept(v)
+	}
+}
+
+// <- Scalar or composite, the visitor doesn't care,
+//	  it just gets every single object in the tree.
+
+// For example, counting how many shapes of each color
+// we have across the whole drawing.
+
+type ColorHistogramVisitor struct {
+	Counts map[string]int
+}
+
+func NewColorHistogramVisitor() *ColorHistogramVisitor {
+	return &ColorHistogramVisitor{map[string]int{}}
+}
+
+func (c *ColorHistogramVisitor) VisitObject(g *GraphicObject) {
+	if len(g.Color) > 0 {
+		c.Counts[g.Color]++
+	}
+}
+
+// <- Groups don't have a color, so they're not counted.
+
+// And since the whole tree is made out of exported fields,
+// saving a drawing is just a matter of marshaling the root.
+// The children get marshaled recursively, just like printing.
+
+func (g *GraphicObject) ToJSON() ([]byte, error) {
+	return json.Marshal(g)
+}
+
+func GraphicObjectFromJSON(data []byte) (*GraphicObject, error) {
+	g := &GraphicObject{}
+	if err := json.Unmarshal(data, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Two drawings are the same if they have the same name and color,
+// and the same children, in the same order. So a doodle with a circle
+// and then a square is not equal to one with a square and then a circle,
+// the order in which things are drawn matters.
+
+func (g *GraphicObject) Equals(other *GraphicObject) bool {
+	if g.Name != other.Name || g.Color != other.Color || g.Size != other.Size ||
+		len(g.Children) != len(other.Children) {
+		return false
+	}
+	for i := range g.Children {
+		if !g.Children[i].Equals(&other.Children[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// And if we want to find duplicate drawings among lots of them,
+// comparing every pair is slow, so we'd rather have a hash of the
+// contents. It has to follow the exact same rules as Equals, so we
+// walk the tree the same way: name, color and size first, then the
+// children, in order.
+
+func (g *GraphicObject) Hash() string {
+	h := sha256.New()
+	g.hashInto(h)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (g *GraphicObject) hashInto(h hash.Hash) {
+	var buf [8]byte
+	writeString := func(s string) {
+		binary.BigEndian.PutUint64(buf[:], uint64(len(s)))
+		h.Write(buf[:])
+		h.Write([]byte(s))
+	}
+	writeString(g.Name)
+	writeString(g.Color)
+	size := g.Size
+	if size == 0 {
+		size = 0 // <- -0 equals 0, so it has to hash the same, too
+	}
+	binary.BigEndian.PutUint32(buf[:4], math.Float32bits(size))
+	h.Write(buf[:4])
+	binary.BigEndian.PutUint64(buf[:], uint64(len(g.Children)))
+	h.Write(buf[:])
+	for i := range g.Children {
+		g.Children[i].hashInto(h)
+	}
+}
+
+// <- Every string is preceded by its length and every group by
+//	  the number of its children, so no two different drawings
+//	  end up writing the very same bytes.
+
+// Now let's give our shapes a size, the radius of a circle or
+// the side of a square, and ask how much of the canvas a whole
+// drawing covers. Only the actual shapes have an area, groups
+// are just containers and contribute nothing by themselves.
+
+func (g *GraphicObject) WithSize(size float32) *GraphicObject {
+	g.Size = size
+	return g
+}
+
+type AreaVisitor struct {
+	Total float32
+}
+
+func (a *AreaVisitor) VisitObject(g *GraphicObject) {
+	if len(g.Children) > 0 {
+		return
+	}
+	switch g.Name {
+	case "Circle":
+		a.Total += math.Pi * g.Size * g.Size
+	case "Square":
+		a.Total += g.Size * g.Size
+	}
+}
+
+// <- A shape we don't know how to measure counts as zero.
+
+func (g *GraphicObject) TotalArea() float32 {
+	a := &AreaVisitor{}
+	g.Accept(a)
+	return a.Total
+}
+
+// Sometimes all we care about are the actual shapes, the leaves,
+// and not the groups they're in. We could collect them all into
+// a slice, but for a really big scene that's a lot of work if we
+// only need the first few of them.
+
+// So instead, we can have an iterator which only goes as deep
+// into the tree as it needs to in order to find the next leaf.
+
+type LeafIterator struct {
+	stack   []*GraphicObject
+	current *GraphicObject
+	visited int
+}
+
+func NewLeafIterator(root *GraphicObject) *LeafIterator {
+	return &LeafIterator{stack: []*GraphicObject{root}}
+}
+
+func (it *LeafIterator) MoveNext() bool {
+	for len(it.stack) > 0 {
+		g := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+		it.visited++
+
+		if len(g.Children) == 0 {
+			it.current = g
+			return true
+		}
+		for i := len(g.Children) - 1; i >= 0; i-- {
+			it.stack = append(it.stack, &g.Children[i])
+		}
+	}
+	it.current = nil
+	return false
+}
+
+// <- Children are pushed in reverse, so they come off
+//	  the stack in the order in which they were drawn.
+
+func (it *LeafIterator) Value() *GraphicObject {
+	return it.current
+}
+
+// <- If we stop calling MoveNext(), the rest of the tree
+//	  is simply never looked at.
+
+// And when we know exactly where something is in a scene, we can
+// get to it by name, one level at a time, "Group 1", then "Circle".
+// Names don't have to be unique, two circles in the same group
+// are both called "Circle", and in that case we take the first one.
+
+func (g *GraphicObject) Find(path ...string) (*GraphicObject, bool) {
+	current := g
+	for _, name := range path {
+		var next *GraphicObject
+		for i := range current.Children {
+			if current.Children[i].Name == name {
+				next = &current.Children[i]
+				break
+			}
+		}
+		if next == nil {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
+
+// <- What we get back points into the tree itself, so changing
+//	  it changes the drawing. An empty path finds the object itself.
+
+// And if we'd rather have a copy we can change freely, say to
+// start a new drawing from a part of an old one, we clone it.
+// Children are values, but they live in a slice, and copying the
+// struct would still share that slice, so every level needs its own.
+
+func (g *GraphicObject) Clone() *GraphicObject {
+	clone := *g
+	if g.Children != nil {
+		clone.Children = make([]GraphicObject, len(g.Children))
+		for i := range g.Children {
+			clone.Children[i] = *g.Children[i].Clone()
+		}
+	}
+	return &clone
+}
+
+// <- It's the prototype all over again, a deep copy
+//	  of whatever part of the composite we start from.
+
 func main() {
-	drawing := GraphicObject{"My Doodle", "", nil}
+	drawing := GraphicObject{Name: "My Doodle"}
 	drawing.Children = append(drawing.Children, *NewCircle("Red"))
 	drawing.Children = append(drawing.Children, *NewSquare("Yellow"))
 
-	group := GraphicObject{"Group 1", "", nil}
+	group := GraphicObject{Name: "Group 1"}
 	group.Children = append(group.Children, *NewCircle("Blue"))
 	group.Children = append(group.Children, *NewSquare("Blue"))
 
 	drawing.Children = append(drawing.Children, group)
 
 	fmt.Println(drawing.String())
+
+	histogram := NewColorHistogramVisitor()
+	drawing.Accept(histogram)
+	fmt.Println("Blue shapes:", histogram.Counts["Blue"])
+
+	data, _ := drawing.ToJSON()
+	fmt.Println(string(data))
+
+	loaded, _ := GraphicObjectFromJSON(data)
+	fmt.Println("Same drawing:", loaded.String() == drawing.String())
+	fmt.Println("Equal:", loaded.Equals(&drawing), loaded.Hash() == drawing.Hash())
+
+	scene := GraphicObject{Name: "Scene", Children: []GraphicObject{
+		*NewCircle("Red").WithSize(1),
+		{Name: "Group", Children: []GraphicObject{
+			*NewCircle("Blue").WithSize(2),
+			*NewSquare("Blue").WithSize(3),
+		}},
+	}}
+	fmt.Printf("Total area: %.2f\n", scene.TotalArea()) // 5π + 9
+
+	leaves := NewLeafIterator(&drawing)
+	if leaves.MoveNext() {
+		fmt.Println("First leaf:", leaves.Value().Color, leaves.Value().Name,
+			"- objects visited:", leaves.visited)
+	}
+
+	if circle, ok := drawing.Find("Group 1", "Circle"); ok {
+		fmt.Println("Found:", circle.Color, circle.Name)
+	}
+	_, ok := drawing.Find("Group 2", "Circle")
+	fmt.Println("Found Group 2:", ok)
+
+	copied := drawing.Clone()
+	copied.Children[0].Color = "Green"
+	if circle, ok := copied.Find("Group 1", "Circle"); ok {
+		circle.Color = "Purple"
+	}
+	fmt.Print(copied.String(), drawing.String())
+	fmt.Println("Original unchanged:", drawing.Equals(loaded))
 }
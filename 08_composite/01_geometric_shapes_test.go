@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func doodle() *GraphicObject {
+	return &GraphicObject{Name: "My Doodle", Children: []GraphicObject{
+		*NewCircle("Red"),
+		*NewSquare("Yellow"),
+		{Name: "Group 1", Children: []GraphicObject{
+			*NewCircle("Blue").WithSize(2),
+			*NewSquare("Blue").WithSize(3),
+		}},
+	}}
+}
+
+func TestColorHistogramVisitor(t *testing.T) {
+	histogram := NewColorHistogramVisitor()
+	doodle().Accept(histogram)
+	want := map[string]int{"Red": 1, "Yellow": 1, "Blue": 2}
+	if len(histogram.Counts) != len(want) {
+		t.Fatalf("got %v, want %v", histogram.Counts, want)
+	}
+	for color, n := range want {
+		if histogram.Counts[color] != n {
+			t.Errorf("%s: got %d, want %d", color, histogram.Counts[color], n)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	d := doodle()
+	data, err := d.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := GraphicObjectFromJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Equals(d) || loaded.String() != d.String() {
+		t.Fatalf("loaded a different drawing:\n%s", loaded)
+	}
+	if _, err := GraphicObjectFromJSON([]byte("{")); err == nil {
+		t.Fatal("expected an error for broken JSON")
+	}
+}
+
+func TestEqualsAndHash(t *testing.T) {
+	a, b := doodle(), doodle()
+	if !a.Equals(b) || a.Hash() != b.Hash() {
+		t.Fatal("identical drawings differ")
+	}
+
+	b.Children[0], b.Children[1] = b.Children[1], b.Children[0]
+	if a.Equals(b) || a.Hash() == b.Hash() {
+		t.Fatal("the order of children is ignored")
+	}
+
+	c := doodle()
+	c.Children[2].Children[1].Size = 4
+	if a.Equals(c) || a.Hash() == c.Hash() {
+		t.Fatal("a nested size is ignored")
+	}
+
+	// the same characters, split differently between name and color
+	x := &GraphicObject{Name: "ab", Color: "c"}
+	y := &GraphicObject{Name: "a", Color: "bc"}
+	if x.Hash() == y.Hash() {
+		t.Fatal("name and color run into each other")
+	}
+
+	zero := &GraphicObject{Name: "Circle", Size: float32(math.Copysign(0, -1))}
+	if !zero.Equals(NewCircle("")) || zero.Hash() != NewCircle("").Hash() {
+		t.Fatal("-0 and 0 are treated differently")
+	}
+}
+
+func TestTotalArea(t *testing.T) {
+	want := float32(4*math.Pi + 9)
+	if got := doodle().TotalArea(); math.Abs(float64(got-want)) > 1e-4 {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	group := &GraphicObject{Name: "Circle", Size: 10, Children: []GraphicObject{*NewSquare("").WithSize(1)}}
+	if got := group.TotalArea(); got != 1 {
+		t.Fatalf("a group contributed to the area: %v", got)
+	}
+}
+
+func TestLeafIteratorIsLazy(t *testing.T) {
+	it := NewLeafIterator(doodle())
+	if !it.MoveNext() || it.Value().Color != "Red" {
+		t.Fatalf("first leaf: %v", it.Value())
+	}
+	if it.visited != 2 {
+		t.Fatalf("visited %d objects for the first leaf, want 2", it.visited)
+	}
+
+	var colors []string
+	for colors = append(colors, it.Value().Color); it.MoveNext(); {
+		colors = append(colors, it.Value().Color)
+	}
+	if len(colors) != 4 || colors[3] != "Blue" || it.Value() != nil {
+		t.Fatalf("leaves: %v", colors)
+	}
+}
+
+func TestFind(t *testing.T) {
+	d := doodle()
+	circle, ok := d.Find("Group 1", "Circle")
+	if !ok || circle.Color != "Blue" {
+		t.Fatalf("got %v, %v", circle, ok)
+	}
+	circle.Color = "Green"
+	if d.Children[2].Children[0].Color != "Green" {
+		t.Fatal("Find returned a copy")
+	}
+	if root, ok := d.Find(); !ok || root != d {
+		t.Fatal("an empty path should find the root")
+	}
+	if _, ok := d.Find("Group 2", "Circle"); ok {
+		t.Fatal("found a missing group")
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	d := doodle()
+	clone := d.Clone()
+	if !clone.Equals(d) {
+		t.Fatal("clone differs")
+	}
+	clone.Children[0].Color = "Green"
+	clone.Children[2].Children[0].Color = "Purple"
+	clone.Children[2].Children = append(clone.Children[2].Children, *NewCircle("Pink"))
+	if !d.Equals(doodle()) {
+		t.Fatalf("changing the clone changed the original:\n%s", d)
+	}
+}
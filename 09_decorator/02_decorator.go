@@ -9,7 +9,14 @@
 
 package main
 
-import "fmt"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
 
 // So let's suppose that we have some sort of interface
 // [Shape] and this interface is going to allow a shape
@@ -17,14 +24,23 @@ import "fmt"
 
 type Shape interface {
 	Render() string
+	RenderTo(w io.Writer, format Format) error
 }
 
+// <- RenderTo is a late addition, more on it at the end.
+
 type Circle struct {
 	Radius float32
 }
 
 func (c *Circle) Render() string {
-	return fmt.Sprintf("Circle of radius: %.2f", c.Radius)
+	return renderText(c)
+}
+
+func (c *Circle) RenderTo(w io.Writer, format Format) error {
+	return renderLeaf(w, format,
+		fmt.Sprintf("Circle of radius: %.2f", c.Radius),
+		fmt.Sprintf(`<circle radius="%.2f" />`, c.Radius))
 }
 
 func (c *Circle) Resize(factor float32) {
@@ -36,7 +52,13 @@ type Square struct {
 }
 
 func (s *Square) Render() string {
-	return fmt.Sprintf("Square with side: %.2f", s.Side)
+	return renderText(s)
+}
+
+func (s *Square) RenderTo(w io.Writer, format Format) error {
+	return renderLeaf(w, format,
+		fmt.Sprintf("Square with side: %.2f", s.Side),
+		fmt.Sprintf(`<square side="%.2f" />`, s.Side))
 }
 
 // Now imagine we these shapes operating in our system,
@@ -69,7 +91,13 @@ type ColoredShape struct {
 // so we can use it's Render() method
 
 func (c *ColoredShape) Render() string {
-	return fmt.Sprintf("%s has the color: %s", c.Shape.Render(), c.Color)
+	return renderText(c)
+}
+
+func (c *ColoredShape) RenderTo(w io.Writer, format Format) error {
+	return renderDecorated(w, format, c.Shape,
+		" has the color: "+c.Color,
+		"color", fmt.Sprintf(`value="%s"`, c.Color))
 }
 
 // <- This is now our firstborn Decorator, and we can start using it.
@@ -106,7 +134,13 @@ type TransparentShape struct {
 }
 
 func (t *TransparentShape) Render() string {
-	return fmt.Sprintf("%s has %f%% transparency", t.Shape.Render(), t.Transparency*100.0)
+	return renderText(t)
+}
+
+func (t *TransparentShape) RenderTo(w io.Writer, format Format) error {
+	return renderDecorated(w, format, t.Shape,
+		fmt.Sprintf(" has %f%% transparency", t.Transparency*100.0),
+		"transparency", fmt.Sprintf(`value="%.0f%%"`, t.Transparency*100.0))
 }
 
 // <- We can use this one over the ColoredShape
@@ -121,6 +155,271 @@ func (t *TransparentShape) Render() string {
 // If we want to start detecting those things it's going to be
 // a lot more work, and to be honest, not sure if it's really worth it.
 
+// What is worth it though, is making sure that the chain of
+// decorators doesn't grow out of control. Every decorator calls
+// Render() of whatever it wraps, so a pathological chain would
+// eventually blow the stack.
+
+// First, we need a way to tell whether a shape is a decorator at all,
+// and the easiest way is for every decorator to expose what it wraps.
+
+type Decorator interface {
+	Shape
+	Inner() Shape
+}
+
+func (c *ColoredShape) Inner() Shape {
+	return c.Shape
+}
+
+func (t *TransparentShape) Inner() Shape {
+	return t.Shape
+}
+
+// Now we can count how many layers of decoration there are.
+
+func WrapDepth(s Shape) int {
+	depth := 0
+	for d, ok := s.(Decorator); ok; d, ok = s.(Decorator) {
+		depth++
+		s = d.Inner()
+	}
+	return depth
+}
+
+// And in the very same way, we can peel off all the layers
+// and get to whatever was decorated in the first place.
+
+func Unwrap(s Shape) Shape {
+	for d, ok := s.(Decorator); ok; d, ok = s.(Decorator) {
+		s = d.Inner()
+	}
+	return s
+}
+
+// Which finally gives us a way around the problem we had with
+// Resize(). Once we know what's at the bottom, we can get it back
+// as its actual type, and use all of its methods again.
+
+func Base[T Shape](s Shape) (T, bool) {
+	t, ok := Unwrap(s).(T)
+	return t, ok
+}
+
+// <- Since decorators hold on to a pointer, resizing the circle
+//	  we got back resizes the decorated one as well.
+
+// And we can guard the construction of decorators,
+// so nobody can wrap a shape more than some maximum depth.
+
+var MaxWrapDepth = 16
+
+var ErrWrapTooDeep = errors.New("decorator: maximum wrap depth exceeded")
+
+func checkWrapDepth(s Shape) error {
+	if WrapDepth(s) >= MaxWrapDepth {
+		return ErrWrapTooDeep
+	}
+	return nil
+}
+
+func NewColoredShape(shape Shape, color string) (*ColoredShape, error) {
+	if err := checkWrapDepth(shape); err != nil {
+		return nil, err
+	}
+	return &ColoredShape{shape, color}, nil
+}
+
+func NewTransparentShape(shape Shape, transparency float32) (*TransparentShape, error) {
+	if err := checkWrapDepth(shape); err != nil {
+		return nil, err
+	}
+	return &TransparentShape{shape, transparency}, nil
+}
+
+// <- Of course, nothing stops anybody from using the struct
+//	  literals directly, the constructors are the safe way in.
+
+// Decorators can also take parameters that need validating,
+// like the width of a border, which only makes sense if it's positive.
+
+type BorderedShape struct {
+	Shape       Shape
+	BorderWidth int
+}
+
+func (b *BorderedShape) Render() string {
+	return renderText(b)
+}
+
+func (b *BorderedShape) RenderTo(w io.Writer, format Format) error {
+	return renderDecorated(w, format, b.Shape,
+		fmt.Sprintf(" with a %d-pixel border", b.BorderWidth),
+		"border", fmt.Sprintf(`width="%d"`, b.BorderWidth))
+}
+
+func (b *BorderedShape) Inner() Shape {
+	return b.Shape
+}
+
+func NewBorderedShape(shape Shape, borderWidth int) (*BorderedShape, error) {
+	if borderWidth <= 0 {
+		return nil, fmt.Errorf("decorator: border width must be positive, got %d", borderWidth)
+	}
+	if err := checkWrapDepth(shape); err != nil {
+		return nil, err
+	}
+	return &BorderedShape{shape, borderWidth}, nil
+}
+
+// Sometimes we don't know up front whether a decoration
+// should apply at all, for example when the styling is behind
+// a feature flag that can be flipped while the program runs.
+
+// So we can have a decorator that decides on every render
+// whether to apply some other decorator, or render the bare shape.
+
+type ConditionalShape struct {
+	Shape    Shape
+	Decorate func(Shape) Shape
+	When     func() bool
+}
+
+func (c *ConditionalShape) Render() string {
+	return renderText(c)
+}
+
+func (c *ConditionalShape) RenderTo(w io.Writer, format Format) error {
+	if c.When() {
+		return c.Decorate(c.Shape).RenderTo(w, format)
+	}
+	return c.Shape.RenderTo(w, format)
+}
+
+func (c *ConditionalShape) Inner() Shape {
+	return c.Shape
+}
+
+// <- The decoration is built lazily, so it costs nothing
+//	  while the condition doesn't hold.
+
+// Shapes aren't safe to share between goroutines, somebody resizing
+// a circle while somebody else renders it is a data race.
+// A decorator can fix that for whatever it wraps, by putting a lock
+// around everything that goes through it.
+
+type Resizer interface {
+	Resize(factor float32)
+}
+
+type SyncShape struct {
+	mu    sync.RWMutex
+	Shape Shape
+}
+
+func NewSyncShape(shape Shape) *SyncShape {
+	return &SyncShape{Shape: shape}
+}
+
+func (s *SyncShape) Render() string {
+	return renderText(s)
+}
+
+func (s *SyncShape) RenderTo(w io.Writer, format Format) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Shape.RenderTo(w, format)
+}
+
+// And this time, we don't lose Resize(). Whatever is at the
+// bottom of the chain gets resized, if it can be resized at all,
+// and if it can't, resizing simply does nothing.
+
+func (s *SyncShape) Resize(factor float32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := Unwrap(s.Shape).(Resizer); ok {
+		r.Resize(factor)
+	}
+}
+
+// <- The very same method as the Resizer interface, so a SyncShape
+//	  is a Resizer itself, and can be handed to anybody who wants one.
+
+var _ Resizer = (*SyncShape)(nil)
+
+func (s *SyncShape) Inner() Shape {
+	return s.Shape
+}
+
+// <- The lock only protects what goes through the decorator.
+//	  Anybody holding on to the circle itself, or getting it back
+//	  with Base(), is on their own.
+
+// Bonus:
+// Every decorator builds a string out of the string of whatever
+// it wraps, so a deep chain keeps concatenating the same text over
+// and over. And the text is all we can ever get out of a shape.
+
+// Instead, every shape can write itself straight into a writer,
+// in whichever format we ask for, either the plain text we've
+// had all along, or a simple markup.
+
+type Format int
+
+const (
+	Text Format = iota
+	Markup
+)
+
+// Leaves just pick the right representation.
+
+func renderLeaf(w io.Writer, format Format, text, markup string) error {
+	switch format {
+	case Text:
+		_, err := io.WriteString(w, text)
+		return err
+	case Markup:
+		_, err := io.WriteString(w, markup)
+		return err
+	}
+	return fmt.Errorf("decorator: unknown format %d", format)
+}
+
+// Decorators write whatever they wrap, and then either append
+// to the text, or put a tag around the markup.
+
+func renderDecorated(w io.Writer, format Format, inner Shape, suffix, tag, attrs string) error {
+	switch format {
+	case Text:
+		if err := inner.RenderTo(w, format); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, suffix)
+		return err
+	case Markup:
+		if _, err := fmt.Fprintf(w, "<%s %s>", tag, attrs); err != nil {
+			return err
+		}
+		if err := inner.RenderTo(w, format); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(w, "</%s>", tag)
+		return err
+	}
+	return fmt.Errorf("decorator: unknown format %d", format)
+}
+
+// And the good old Render() is now just the text format.
+
+func renderText(s Shape) string {
+	sb := strings.Builder{}
+	s.RenderTo(&sb, Text)
+	return sb.String()
+}
+
+// <- Writing into a strings.Builder never fails.
+
 func main() {
 	circle := Circle{2}
 	circle.Resize(2)
@@ -131,4 +430,59 @@ func main() {
 
 	rhsCircle := TransparentShape{&redCircle, 0.5}
 	fmt.Println(rhsCircle.Render())
+	fmt.Println("Wrap depth:", WrapDepth(&rhsCircle))
+
+	if c, ok := Base[*Circle](&rhsCircle); ok {
+		c.Resize(0.5)
+		fmt.Println(rhsCircle.Render())
+	}
+
+	if bordered, err := NewBorderedShape(&redCircle, 3); err == nil {
+		fmt.Println(bordered.Render())
+	}
+
+	var shape Shape = &circle
+	for {
+		colored, err := NewColoredShape(shape, "Blue")
+		if err != nil {
+			fmt.Println(err, "at depth", WrapDepth(shape))
+			break
+		}
+		shape = colored
+	}
+
+	highlight := false
+	flagged := &ConditionalShape{
+		Shape:    &Square{1},
+		Decorate: func(s Shape) Shape { return &ColoredShape{s, "Yellow"} },
+		When:     func() bool { return highlight },
+	}
+	fmt.Println(flagged.Render())
+	highlight = true
+	fmt.Println(flagged.Render())
+
+	var buf bytes.Buffer
+	for _, format := range []Format{Text, Markup} {
+		buf.Reset()
+		if err := rhsCircle.RenderTo(&buf, format); err != nil {
+			fmt.Println(err)
+		}
+		fmt.Println(buf.String())
+	}
+
+	shared := NewSyncShape(&ColoredShape{&Circle{1}, "Green"})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			shared.Resize(1.01)
+		}()
+		go func() {
+			defer wg.Done()
+			shared.Render()
+		}()
+	}
+	wg.Wait()
+	fmt.Println(shared.Render())
 }
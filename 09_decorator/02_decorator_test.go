@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWrapDepthLimit(t *testing.T) {
+	var shape Shape = &Circle{1}
+	for i := 0; i < MaxWrapDepth; i++ {
+		colored, err := NewColoredShape(shape, "Blue")
+		if err != nil {
+			t.Fatalf("depth %d: %v", i, err)
+		}
+		shape = colored
+	}
+	if WrapDepth(shape) != MaxWrapDepth {
+		t.Fatalf("depth %d, want %d", WrapDepth(shape), MaxWrapDepth)
+	}
+	if _, err := NewColoredShape(shape, "Red"); !errors.Is(err, ErrWrapTooDeep) {
+		t.Errorf("colored: got %v", err)
+	}
+	if _, err := NewTransparentShape(shape, 0.5); !errors.Is(err, ErrWrapTooDeep) {
+		t.Errorf("transparent: got %v", err)
+	}
+	if _, err := NewBorderedShape(shape, 1); !errors.Is(err, ErrWrapTooDeep) {
+		t.Errorf("bordered: got %v", err)
+	}
+}
+
+func TestBorderedShape(t *testing.T) {
+	if _, err := NewBorderedShape(&Circle{1}, 0); err == nil {
+		t.Error("accepted a zero width border")
+	}
+	b, err := NewBorderedShape(&Square{2}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.Render(); got != "Square with side: 2.00 with a 3-pixel border" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestConditionalShape(t *testing.T) {
+	highlight := false
+	flagged := &ConditionalShape{
+		Shape:    &Square{1},
+		Decorate: func(s Shape) Shape { return &ColoredShape{s, "Yellow"} },
+		When:     func() bool { return highlight },
+	}
+	if got := flagged.Render(); got != "Square with side: 1.00" {
+		t.Errorf("off: got %q", got)
+	}
+	highlight = true
+	if got := flagged.Render(); got != "Square with side: 1.00 has the color: Yellow" {
+		t.Errorf("on: got %q", got)
+	}
+}
+
+func TestRenderToFormats(t *testing.T) {
+	shape := &TransparentShape{&ColoredShape{&Circle{2}, "Red"}, 0.5}
+	tests := map[Format]string{
+		Text:   "Circle of radius: 2.00 has the color: Red has 50.000000% transparency",
+		Markup: `<transparency value="50%"><color value="Red"><circle radius="2.00" /></color></transparency>`,
+	}
+	for format, want := range tests {
+		var buf bytes.Buffer
+		if err := shape.RenderTo(&buf, format); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("format %d: got %q, want %q", format, buf.String(), want)
+		}
+	}
+	if shape.Render() != tests[Text] {
+		t.Error("Render() is not the text format")
+	}
+	if err := shape.RenderTo(&bytes.Buffer{}, Format(42)); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestUnwrapAndBase(t *testing.T) {
+	circle := &Circle{2}
+	shape := &TransparentShape{&ColoredShape{circle, "Red"}, 0.5}
+	if Unwrap(shape) != circle {
+		t.Fatal("Unwrap did not return the circle")
+	}
+	if c, ok := Base[*Circle](shape); !ok || c != circle {
+		t.Fatal("Base did not return the circle")
+	}
+	if _, ok := Base[*Square](shape); ok {
+		t.Fatal("Base found a square")
+	}
+}
+
+func TestSyncShapeConcurrentResize(t *testing.T) {
+	circle := &Circle{1}
+	shared := NewSyncShape(&ColoredShape{circle, "Green"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			shared.Resize(2)
+		}()
+		go func() {
+			defer wg.Done()
+			if !strings.HasSuffix(shared.Render(), "has the color: Green") {
+				t.Error("render went wrong")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if circle.Radius != 1024 {
+		t.Fatalf("radius %v, want 1024", circle.Radius)
+	}
+	// a square can't be resized, so nothing happens
+	NewSyncShape(&Square{1}).Resize(2)
+}
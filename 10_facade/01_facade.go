@@ -15,7 +15,11 @@
 
 package main
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
 
 // So if we were to build such a thing, we would start with
 // making a buffer.
@@ -39,6 +43,10 @@ func (b *Buffer) At(index int) rune {
 	return b.buffer[index]
 }
 
+func (b *Buffer) Set(index int, r rune) {
+	b.buffer[index] = r
+}
+
 // So this is one of the components of our rather complicated
 // system, and the of course, we need to present this Buffer on
 // the screen.
@@ -51,10 +59,16 @@ func (b *Buffer) At(index int) rune {
 type Viewport struct {
 	buffer *Buffer
 	offset int
+	height int
 }
 
+// <- A viewport only shows so many lines at a time,
+//	  no matter how long the buffer behind it is.
+
+const visibleLines = 25
+
 func NewViewport(buffer *Buffer) *Viewport {
-	return &Viewport{buffer: buffer}
+	return &Viewport{buffer: buffer, height: min(visibleLines, buffer.height)}
 }
 
 // And in the followint fashion, we can have another
@@ -65,9 +79,40 @@ func (v *Viewport) GetCharacter(index int) rune {
 	return v.buffer.At(v.offset + index)
 }
 
+func (v *Viewport) SetCharacter(index int, r rune) {
+	v.buffer.Set(v.offset+index, r)
+}
+
 // <- This way we get the character from the start of
 // the visible area as opposed to the start of the entire buffer.
 
+// And the same goes for a whole line of the visible area.
+// The cells nobody has written to yet show up as blanks, so that
+// whatever comes after them stays in its column, but the blanks
+// at the very end of the line are left out.
+
+func (v *Viewport) Line(row int) string {
+	var line []rune
+	written := 0
+	for col := 0; col < v.buffer.width; col++ {
+		index := v.offset + row*v.buffer.width + col
+		if index >= len(v.buffer.buffer) {
+			break
+		}
+		r := v.buffer.At(index)
+		if r == 0 {
+			r = ' '
+		} else {
+			written = col + 1
+		}
+		line = append(line, r)
+	}
+	return string(line[:written])
+}
+
+// <- Only the cells nobody wrote to are trimmed,
+//	  spaces which were actually written stay put.
+
 // So now we have a situation where we have Buffer and Viewport
 // and we can imagine a Console, multi-buffer console, being a kind
 // of combination.
@@ -81,6 +126,8 @@ type Console struct {
 	buffer    []*Buffer
 	viewports []*Viewport
 	offset    int
+	cursor    int
+	history   []consoleWrite
 }
 
 // And what we're going to have here is we're going
@@ -97,7 +144,7 @@ type Console struct {
 func NewConsole() *Console {
 	b := NewBuffer(200, 150)
 	v := NewViewport(b)
-	return &Console{[]*Buffer{b}, []*Viewport{v}, 0}
+	return &Console{buffer: []*Buffer{b}, viewports: []*Viewport{v}}
 }
 
 // And once again, now that we have a Console, we can
@@ -116,6 +163,99 @@ func (c *Console) GetCharacterAt(index int) rune {
 // This way we would use this instead of working with
 // low-level constructs like buffers and viewports.
 
+// A console we can only read from isn't much of a console,
+// so let's be able to write to it as well. And since people
+// make mistakes, let's also be able to take back what we wrote.
+
+// To undo a write we have to remember what every cell
+// looked like before the write changed it.
+
+type cellChange struct {
+	index int
+	old   rune
+}
+
+type consoleWrite struct {
+	cursor  int
+	changes []cellChange
+}
+
+// <- We don't want to keep every write ever made,
+//	  only the last few of them.
+
+const maxHistory = 10
+
+// <- And the buffer, large as it is, still ends somewhere.
+//	  A write which doesn't fit is refused as a whole,
+//	  rather than leaving half of it on the screen.
+
+var ErrBufferFull = errors.New("console: write past the end of the buffer")
+
+func (c *Console) Write(text string) error {
+	v := c.viewports[0]
+	runes := []rune(text)
+	if v.offset+c.cursor+len(runes) > len(v.buffer.buffer) {
+		return ErrBufferFull
+	}
+
+	w := consoleWrite{cursor: c.cursor}
+	for _, r := range runes {
+		w.changes = append(w.changes, cellChange{c.cursor, v.GetCharacter(c.cursor)})
+		v.SetCharacter(c.cursor, r)
+		c.cursor++
+	}
+
+	c.history = append(c.history, w)
+	if len(c.history) > maxHistory {
+		n := copy(c.history, c.history[1:])
+		c.history[n] = consoleWrite{} // <- let go of the oldest write's changes
+		c.history = c.history[:n]
+	}
+	return nil
+}
+
+// <- Slicing off the front, history[1:], would keep the same backing
+//	  array around and keep growing it with every append, whereas
+//	  copying everything one step to the left reuses it.
+
+// Undoing is just putting back the old characters, in reverse
+// order, and moving the cursor back to where the write started.
+
+func (c *Console) Undo() bool {
+	if len(c.history) == 0 {
+		return false
+	}
+	w := c.history[len(c.history)-1]
+	c.history = c.history[:len(c.history)-1]
+
+	v := c.viewports[0]
+	for i := len(w.changes) - 1; i >= 0; i-- {
+		v.SetCharacter(w.changes[i].index, w.changes[i].old)
+	}
+	c.cursor = w.cursor
+	return true
+}
+
+// <- Whoever uses the console never sees any of these cell
+//	  changes, all they know is that they can Write and Undo.
+
+// And most of the time, whoever uses the console just wants to
+// know what's on the screen right now. They shouldn't have to
+// go through the viewport for that, and they certainly shouldn't
+// be able to change the screen by changing what we gave them.
+
+func (c *Console) Snapshot() []string {
+	v := c.viewports[0]
+	lines := make([]string, v.height)
+	for row := range lines {
+		lines[row] = v.Line(row)
+	}
+	return lines
+}
+
+// <- The slice is freshly made on every call, and the strings in it
+//	  are immutable anyway, so the snapshot is entirely the caller's.
+
 // Recap:
 // -> The idea of Facade is basically providing a simple API
 //    over something that's complicated
@@ -136,4 +276,22 @@ func main() {
 	c := NewConsole()
 	u := c.GetCharacterAt(1)
 	fmt.Println(u)
+
+	c.Write("hello")
+	c.Write(" world")
+	c.Undo()
+	if err := c.Write(strings.Repeat(".", 200*150)); err != nil {
+		fmt.Println(err)
+	}
+
+	var line []rune
+	for i := 0; c.GetCharacterAt(i) != 0; i++ {
+		line = append(line, c.GetCharacterAt(i))
+	}
+	fmt.Println(string(line))
+
+	snapshot := c.Snapshot()
+	snapshot[0] = "overwritten"
+	fmt.Printf("%d visible lines, first one still %q\n",
+		len(c.Snapshot()), c.Snapshot()[0])
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteAndUndo(t *testing.T) {
+	c := NewConsole()
+	c.Write("hello")
+	c.Write(" world")
+	if got := c.Snapshot()[0]; got != "hello world" {
+		t.Fatalf("got %q", got)
+	}
+
+	if !c.Undo() {
+		t.Fatal("nothing to undo")
+	}
+	if got := c.Snapshot()[0]; got != "hello" {
+		t.Fatalf("after one undo: %q", got)
+	}
+	c.Write("!")
+	if got := c.Snapshot()[0]; got != "hello!" {
+		t.Fatalf("the cursor did not move back: %q", got)
+	}
+
+	c.Undo()
+	c.Undo()
+	if c.Undo() {
+		t.Fatal("undid more writes than were made")
+	}
+	if got := c.Snapshot()[0]; got != "" {
+		t.Fatalf("after undoing everything: %q", got)
+	}
+}
+
+func TestUndoKeepsLastWrites(t *testing.T) {
+	c := NewConsole()
+	for i := 0; i < maxHistory+5; i++ {
+		c.Write("x")
+	}
+	undone := 0
+	for c.Undo() {
+		undone++
+	}
+	if undone != maxHistory {
+		t.Fatalf("undid %d writes, want %d", undone, maxHistory)
+	}
+	if got := c.Snapshot()[0]; got != "xxxxx" {
+		t.Fatalf("got %q, the oldest writes should stay", got)
+	}
+}
+
+func TestWritePastBufferEnd(t *testing.T) {
+	c := NewConsole()
+	size := len(c.buffer[0].buffer)
+	if err := c.Write(strings.Repeat(".", size-1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write("ab"); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("got %v, want ErrBufferFull", err)
+	}
+	if err := c.Write("a"); err != nil {
+		t.Fatalf("the last cell: %v", err)
+	}
+	if err := c.Write("b"); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("got %v, want ErrBufferFull", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	c := NewConsole()
+	v := c.viewports[0]
+	v.SetCharacter(3, 'a')
+	v.SetCharacter(v.buffer.width+1, 'b')
+
+	snapshot := c.Snapshot()
+	if len(snapshot) != visibleLines {
+		t.Fatalf("%d lines, want %d", len(snapshot), visibleLines)
+	}
+	if snapshot[0] != "   a" || snapshot[1] != " b" || snapshot[2] != "" {
+		t.Fatalf("got %q", snapshot[:3])
+	}
+
+	c.Write("hi ")
+	if got := c.Snapshot()[0]; got != "hi a" {
+		t.Fatalf("got %q", got)
+	}
+
+	snapshot[0] = "overwritten"
+	if c.Snapshot()[0] == "overwritten" {
+		t.Fatal("changing the snapshot changed the console")
+	}
+}
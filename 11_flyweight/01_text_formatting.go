@@ -151,6 +151,67 @@ func (b *BetterFormatedText) String() string {
 // text ranges, but these temporary objects allow us to save a lot of memory
 // which is always a good thing, right?
 
+// Bonus:
+// Real editors go even further with this.
+// Every character on the screen is drawn using a glyph,
+// and there are only so many different characters in so
+// many different styles, so there's no point in having
+// a separate glyph for every single character of the text.
+
+type Style struct {
+	Capitalize, Bold, Italic bool
+}
+
+type Glyph struct {
+	Rune     rune
+	Style    Style
+	Rendered string
+}
+
+// The glyph cache is where all the glyphs live, and
+// the combination of a rune and a style is the key.
+
+type glyphKey struct {
+	r     rune
+	style Style
+}
+
+type GlyphCache struct {
+	glyphs map[glyphKey]*Glyph
+}
+
+func NewGlyphCache() *GlyphCache {
+	return &GlyphCache{map[glyphKey]*Glyph{}}
+}
+
+// Whenever somebody asks for a glyph we either give them
+// the one we already have, or we render it once and keep it.
+
+func (g *GlyphCache) Get(r rune, style Style) *Glyph {
+	key := glyphKey{r, style}
+	if glyph, ok := g.glyphs[key]; ok {
+		return glyph
+	}
+
+	rendered := string(r)
+	if style.Capitalize {
+		rendered = string(unicode.ToUpper(r))
+	}
+	if style.Italic {
+		rendered = "_" + rendered + "_"
+	}
+	if style.Bold {
+		rendered = "**" + rendered + "**"
+	}
+
+	glyph := &Glyph{r, style, rendered}
+	g.glyphs[key] = glyph
+	return glyph
+}
+
+// <- So no matter how many times the letter 'e' shows up
+//	  in bold, there's only ever one bold 'e' glyph.
+
 func main() {
 	text := "This is a brave new world"
 	ft := NewFormattedText(text)
@@ -166,4 +227,8 @@ func main() {
 	//     better formatted text, but it also gets returned the client
 
 	fmt.Println(bft.String())
+
+	cache := NewGlyphCache()
+	bold := Style{Bold: true}
+	fmt.Println(cache.Get('w', bold).Rendered, cache.Get('w', bold) == cache.Get('w', bold))
 }
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestGlyphCacheSharesGlyphs(t *testing.T) {
+	cache := NewGlyphCache()
+	bold := Style{Bold: true}
+
+	a, b := cache.Get('w', bold), cache.Get('w', bold)
+	if a != b {
+		t.Fatal("the same rune and style gave different glyphs")
+	}
+	if cache.Get('w', Style{}) == a || cache.Get('v', bold) == a {
+		t.Fatal("a different rune or style shared a glyph")
+	}
+	if len(cache.glyphs) != 3 {
+		t.Fatalf("%d glyphs cached, want 3", len(cache.glyphs))
+	}
+}
+
+func TestGlyphRendering(t *testing.T) {
+	cache := NewGlyphCache()
+	tests := []struct {
+		style Style
+		want  string
+	}{
+		{Style{}, "w"},
+		{Style{Capitalize: true}, "W"},
+		{Style{Italic: true}, "_w_"},
+		{Style{Bold: true}, "**w**"},
+		{Style{true, true, true}, "**_W_**"},
+	}
+	for _, tt := range tests {
+		if got := cache.Get('w', tt.style).Rendered; got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.style, got, tt.want)
+		}
+	}
+}
@@ -11,7 +11,10 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -36,9 +39,47 @@ func NewUser(fullname string) *User {
 // And every time we store one of those similar namses we're
 // effectively duplicating memory, duplicating things.
 
-// So what we could do here is we'll make a variable.
+// So what we could do here is we'll make a pool of names,
+// where every unique name is stored exactly once.
 
-var allNames []string
+type NamePool struct {
+	names []string
+}
+
+func (p *NamePool) getOrAdd(s string) uint8 {
+	for i := range p.names {
+		if p.names[i] == s {
+			return uint8(i)
+		}
+	}
+	p.names = append(p.names, s)
+	return uint8(len(p.names) - 1)
+}
+
+func (p *NamePool) Name(id uint8) string {
+	return p.names[id]
+}
+
+// The users only hold on to indices, so if we want to keep
+// the users around between runs, we have to keep the pool as well,
+// and every name has to end up at exactly the same index.
+// A JSON array keeps the order, so that's all we need.
+
+func (p *NamePool) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(p.names)
+}
+
+func LoadNamePool(r io.Reader) (*NamePool, error) {
+	p := &NamePool{}
+	if err := json.NewDecoder(r).Decode(&p.names); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// And a variable holding the pool that all of our users share.
+
+var allNames = &NamePool{}
 
 type FrugalUser struct {
 	names []uint8
@@ -53,24 +94,27 @@ type FrugalUser struct {
 // will be more complicated.
 
 func NewFrugalUser(fullName string) *FrugalUser {
-	getOrAdd := func(s string) uint8 {
-		for i := range allNames {
-			if allNames[i] == s {
-				return uint8(i)
-			}
-		}
-		allNames = append(allNames, s)
-		return uint8(len(allNames) - 1)
-	}
-
 	result := FrugalUser{}
-	parts := strings.Split(fullName, " ")
-	for _, p := range parts {
-		result.names = append(result.names, getOrAdd(p))
-	}
+	result.Rename(fullName)
 	return &result
 }
 
+// <- Building the indices is the same thing as renaming
+//	  a user which has no name yet, so let's have that instead.
+
+// People change their names, so the user has to be able
+// to take on a new one. The parts that are already in the pool
+// keep their index, only the new ones get added.
+
+func (fu *FrugalUser) Rename(newFull string) {
+	fu.names = fu.names[:0]
+	for _, p := range strings.Split(newFull, " ") {
+		fu.names = append(fu.names, allNames.getOrAdd(p))
+	}
+}
+
+// <- The old parts stay in the pool, other users might be using them.
+
 // Now of course, we have a problem here.
 // If we wanted to get the full name of FrugalUser there is
 // no full name as a single element, instead we have a bunch of
@@ -82,7 +126,7 @@ func NewFrugalUser(fullName string) *FrugalUser {
 func (fu *FrugalUser) FullName() string {
 	var parts []string
 	for _, id := range fu.names {
-		parts = append(parts, allNames[id])
+		parts = append(parts, allNames.Name(id))
 	}
 	return strings.Join(parts, " ")
 }
@@ -92,6 +136,26 @@ func (fu *FrugalUser) FullName() string {
 // there are certain memory savings, and the question is how much
 // memory are we actually saving?
 
+// Rather than adding it all up by hand, let's have a function
+// which tells us how much memory the frugal users take, and how
+// much they would take if every user stored the full name as a string.
+
+func MemoryStats(users []*FrugalUser, pool *NamePool) (flyweightBytes, naiveBytes int) {
+	for _, name := range pool.names {
+		flyweightBytes += len(name)
+	}
+	for _, u := range users {
+		flyweightBytes += len(u.names)
+
+		var parts []string
+		for _, id := range u.names {
+			parts = append(parts, pool.Name(id))
+		}
+		naiveBytes += len(strings.Join(parts, " "))
+	}
+	return flyweightBytes, naiveBytes
+}
+
 // So not bad, for couple of user we're saving a few bytes.
 // In a really large scenario we would save huge amounts of memory.
 // Because essentially, we're storing byte arrays of just two bytes per user.
@@ -124,13 +188,27 @@ func main() {
 	frugalAmanda := NewFrugalUser("Amanda Hugandkiss")
 	frugalAlsoAmanda := NewFrugalUser("Amanda Doe")
 
-	totalMem := 0
-	for _, a := range allNames {
-		totalMem += len([]byte(a))
-	}
-	totalMem += len(frugalJohn.names)
-	totalMem += len(frugalAmanda.names)
-	totalMem += len(frugalAlsoAmanda.names)
+	totalMem, _ := MemoryStats(
+		[]*FrugalUser{frugalJohn, frugalAmanda, frugalAlsoAmanda}, allNames)
 
 	fmt.Println("Memory taken by frugal users: ", totalMem)
+
+	before, poolSize := frugalJohn.names[0], len(allNames.names)
+	frugalJohn.Rename("John Smith")
+	fmt.Println(frugalJohn.FullName(),
+		"- John kept index", before, "=", frugalJohn.names[0],
+		"and the pool grew by", len(allNames.names)-poolSize)
+
+	var saved bytes.Buffer
+	if err := allNames.Save(&saved); err != nil {
+		fmt.Println(err)
+		return
+	}
+	loaded, err := LoadNamePool(&saved)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	allNames = loaded // <- as if we've just started up again
+	fmt.Println("After reloading the pool:", frugalJohn.FullName(), "and", frugalAmanda.FullName())
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func freshPool(t *testing.T) {
+	old := allNames
+	allNames = &NamePool{}
+	t.Cleanup(func() { allNames = old })
+}
+
+func TestFrugalUsersShareNames(t *testing.T) {
+	freshPool(t)
+	john := NewFrugalUser("John Doe")
+	amanda := NewFrugalUser("Amanda Doe")
+
+	if john.FullName() != "John Doe" || amanda.FullName() != "Amanda Doe" {
+		t.Fatalf("got %q and %q", john.FullName(), amanda.FullName())
+	}
+	if john.names[1] != amanda.names[1] || len(allNames.names) != 3 {
+		t.Fatalf("Doe is not shared: %v", allNames.names)
+	}
+}
+
+func TestMemoryStats(t *testing.T) {
+	freshPool(t)
+	users := []*FrugalUser{
+		NewFrugalUser("John Doe"),
+		NewFrugalUser("Amanda Hugandkiss"),
+		NewFrugalUser("Amanda Doe"),
+	}
+	flyweight, naive := MemoryStats(users, allNames)
+
+	// John, Doe, Amanda and Hugandkiss once, plus one byte per name part
+	if want := len("JohnDoeAmandaHugandkiss") + 6; flyweight != want {
+		t.Errorf("flyweight: got %d, want %d", flyweight, want)
+	}
+	if want := len("John Doe") + len("Amanda Hugandkiss") + len("Amanda Doe"); naive != want {
+		t.Errorf("naive: got %d, want %d", naive, want)
+	}
+}
+
+func TestRenameAddsAndRemovesParts(t *testing.T) {
+	freshPool(t)
+	u := NewFrugalUser("John Doe")
+	john := u.names[0]
+
+	u.Rename("John Ronald Reuel Tolkien")
+	if u.FullName() != "John Ronald Reuel Tolkien" || u.names[0] != john {
+		t.Fatalf("got %q, %v", u.FullName(), u.names)
+	}
+	u.Rename("John")
+	if u.FullName() != "John" || len(u.names) != 1 {
+		t.Fatalf("got %q, %v", u.FullName(), u.names)
+	}
+	if len(allNames.names) != 5 {
+		t.Fatalf("pool %v, the names stay for everybody else", allNames.names)
+	}
+}
+
+func TestSaveAndLoadNamePool(t *testing.T) {
+	freshPool(t)
+	u := NewFrugalUser("Amanda Hugandkiss")
+
+	var saved bytes.Buffer
+	if err := allNames.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadNamePool(&saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allNames = loaded
+	if u.FullName() != "Amanda Hugandkiss" {
+		t.Fatalf("after loading: %q", u.FullName())
+	}
+
+	if _, err := LoadNamePool(strings.NewReader("not json")); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,139 @@
+// Proxy - Remote Proxy
+
+// There's one more flavor of proxy that we need to
+// take a look at, and that's the Remote Proxy.
+
+// The idea is that the object we're working with
+// doesn't actually live in our process at all.
+// It lives somewhere else, on some server, and every call
+// we make has to be turned into a request, sent over the
+// network, and the result has to come back.
+
+// But the client doesn't have to know any of that.
+// As far as the client is concerned, it's just an ordinary object.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// We'll reuse the Image and the Bitmap from the previous lesson.
+
+type Image interface {
+	Draw()
+}
+
+type Bitmap struct {
+	filename string
+}
+
+func (b *Bitmap) Draw() {
+	fmt.Println("Drawing image", b.filename)
+}
+
+func NewBitmap(filename string) *Bitmap {
+	fmt.Println("Loading the image from", filename)
+	return &Bitmap{filename: filename}
+}
+
+func DrawImage(image Image) {
+	fmt.Println("About to draw the image")
+	image.Draw()
+	fmt.Println("Done drawing the image")
+}
+
+// Now, whatever goes over the wire has to be serialized,
+// so we need some sort of request.
+
+type DrawRequest struct {
+	Filename string `json:"filename"`
+}
+
+// And instead of an actual network, we'll simulate a round trip
+// with a handler function. It receives the serialized request and
+// either does the work on the "other side" or fails.
+
+type Handler func(payload []byte) error
+
+// The other side is the one with the actual bitmaps.
+
+func NewImageServer() Handler {
+	bitmaps := map[string]*Bitmap{}
+	return func(payload []byte) error {
+		var req DrawRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return err
+		}
+		if _, ok := bitmaps[req.Filename]; !ok {
+			bitmaps[req.Filename] = NewBitmap(req.Filename)
+		}
+		bitmaps[req.Filename].Draw()
+		return nil
+	}
+}
+
+// Now the remote image, our proxy.
+// Since networks are slow and unreliable, we can configure
+// an artificial latency, as well as inject a failure.
+
+type RemoteImage struct {
+	filename string
+	handler  Handler
+	Latency  time.Duration
+	Fail     error
+	err      error
+}
+
+func NewRemoteImage(filename string, handler Handler) *RemoteImage {
+	return &RemoteImage{filename: filename, handler: handler}
+}
+
+// It implements the Image interface, so it can be drawn
+// like any other image, but all it really does is it serializes
+// the call and sends it off.
+
+func (r *RemoteImage) Draw() {
+	payload, err := json.Marshal(DrawRequest{r.filename})
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	time.Sleep(r.Latency)
+	if r.Fail != nil {
+		r.err = r.Fail
+		return
+	}
+	r.err = r.handler(payload)
+}
+
+// <- The Image interface has no room for an error,
+//	  so we keep the result of the last call around.
+
+func (r *RemoteImage) Err() error {
+	return r.err
+}
+
+// Recap:
+// -> The remote proxy makes a remote object look like a local one
+// -> The client only ever sees the Image interface, the serialization
+//	  and the round trip are hidden inside the proxy
+// -> The price is that things can now go wrong that never could
+//	  with a local object, and we have to find a way to report them
+
+func main() {
+	server := NewImageServer()
+
+	img := NewRemoteImage("remote-demo.png", server)
+	img.Latency = 100 * time.Millisecond
+	DrawImage(img)
+	fmt.Println("Error:", img.Err())
+
+	img.Fail = errors.New("connection reset by peer")
+	DrawImage(img)
+	fmt.Println("Error:", img.Err())
+}
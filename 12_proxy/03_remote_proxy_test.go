@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRemoteImageSendsRequest(t *testing.T) {
+	var requests []DrawRequest
+	handler := func(payload []byte) error {
+		var req DrawRequest
+		if err := json.Unmarshal(payload, &req); err != nil {
+			return err
+		}
+		requests = append(requests, req)
+		return nil
+	}
+
+	img := NewRemoteImage("remote.png", handler)
+	var _ Image = img
+	img.Draw()
+	img.Draw()
+
+	if img.Err() != nil {
+		t.Fatal(img.Err())
+	}
+	if len(requests) != 2 || requests[0].Filename != "remote.png" {
+		t.Fatalf("got %v", requests)
+	}
+}
+
+func TestRemoteImageLatencyAndFailure(t *testing.T) {
+	called := false
+	img := NewRemoteImage("remote.png", func([]byte) error {
+		called = true
+		return nil
+	})
+	img.Latency = 20 * time.Millisecond
+	img.Fail = errors.New("connection reset by peer")
+
+	start := time.Now()
+	img.Draw()
+	if time.Since(start) < img.Latency {
+		t.Error("the latency was not simulated")
+	}
+	if !errors.Is(img.Err(), img.Fail) || called {
+		t.Fatalf("got %v, called %v", img.Err(), called)
+	}
+
+	img.Fail, img.Latency = nil, 0
+	img.Draw()
+	if img.Err() != nil || !called {
+		t.Fatalf("after recovering: %v, called %v", img.Err(), called)
+	}
+}
+
+func TestImageServerReportsBadPayload(t *testing.T) {
+	server := NewImageServer()
+	if err := server([]byte("{")); err == nil {
+		t.Fatal("expected an error")
+	}
+	if err := server([]byte(`{"filename":"a.png"}`)); err != nil {
+		t.Fatal(err)
+	}
+}
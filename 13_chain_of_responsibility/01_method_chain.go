@@ -11,7 +11,10 @@
 
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type Creature struct {
 	Name            string
@@ -44,19 +47,27 @@ func (c *Creature) String() string {
 type Modifier interface {
 	Add(m Modifier)
 	Handle()
+	SetTrace(t *Trace)
+	Skip()
+	base() *CreatureModifier
 }
 
+// <- Ignore SetTrace, Skip and base for now, we'll come back to them.
+
 // With the interface sorted out, we also need some sort of concrete type.
 
 type CreatureModifier struct {
 	creature *Creature
 	next     Modifier // a linked list of modifiers [singly linked list]
+	name     string
+	trace    *Trace
 }
 
 func (c *CreatureModifier) Add(m Modifier) {
 	if c.next != nil {
 		c.next.Add(m)
 	} else {
+		m.SetTrace(c.trace)
 		c.next = m
 	}
 }
@@ -97,6 +108,7 @@ func NewDoubleAttackModifier(c *Creature) *DoubleAttackModifier {
 	return &DoubleAttackModifier{
 		CreatureModifier{
 			creature: c,
+			name:     "DoubleAttack",
 		},
 	}
 }
@@ -104,6 +116,7 @@ func NewDoubleAttackModifier(c *Creature) *DoubleAttackModifier {
 func (d *DoubleAttackModifier) Handle() {
 	fmt.Println("Doubling", d.creature.Name, "\b's attack")
 	d.creature.Attack *= 2
+	d.record(Applied)
 	d.CreatureModifier.Handle() // <- we propagate the application of every single
 	//								  one of these modifiers in the hierarchy
 }
@@ -118,6 +131,7 @@ func NewIncreaseDefenseModifier(c *Creature) *IncreaseDefenseModifier {
 	return &IncreaseDefenseModifier{
 		CreatureModifier{
 			creature: c,
+			name:     "IncreaseDefense",
 		},
 	}
 }
@@ -126,6 +140,9 @@ func (i *IncreaseDefenseModifier) Handle() {
 	if i.creature.Attack <= 2 {
 		fmt.Println("Increasing", i.creature.Name, "\b's defense")
 		i.creature.Defense++
+		i.record(Applied)
+	} else {
+		i.record(Skipped)
 	}
 	i.CreatureModifier.Handle()
 }
@@ -142,12 +159,15 @@ func NewNoBufsModifier(c *Creature) *NoBufsModifier {
 	return &NoBufsModifier{
 		CreatureModifier{
 			creature: c,
+			name:     "NoBufs",
 		},
 	}
 }
 
 func (d *NoBufsModifier) Handle() {
-	// empty for a reason
+	// nothing gets applied, but we do leave a trace
+	d.record(Blocked)
+	d.skipRest()
 }
 
 // ↑↑↑ This means that every single modifier which comes after this one
@@ -156,6 +176,197 @@ func (d *NoBufsModifier) Handle() {
 // This modifier has prevented the traversal of the entire linked list,
 // thereby preventing the application of any other modifier.
 
+// Now, once we have a long list of modifiers, it gets
+// pretty hard to tell why a creature ended up with the stats
+// that it has. So let's have an optional trace, where every
+// modifier leaves a record of what it did.
+
+type Action int
+
+const (
+	Applied Action = iota
+	Skipped
+	Blocked
+)
+
+func (a Action) String() string {
+	switch a {
+	case Applied:
+		return "applied"
+	case Skipped:
+		return "skipped"
+	case Blocked:
+		return "blocked the rest"
+	}
+	return "unknown"
+}
+
+type TraceRecord struct {
+	Modifier string
+	Action   Action
+}
+
+type Trace struct {
+	Records []TraceRecord
+}
+
+func (t *Trace) String() string {
+	sb := strings.Builder{}
+	for _, r := range t.Records {
+		sb.WriteString(fmt.Sprintf("%s: %s\n", r.Modifier, r.Action))
+	}
+	return sb.String()
+}
+
+// The trace is shared by the whole chain, so setting it
+// on one modifier passes it on to everything after it.
+// And whatever gets added later on picks it up in Add().
+
+func (c *CreatureModifier) SetTrace(t *Trace) {
+	c.trace = t
+	if c.next != nil {
+		c.next.SetTrace(t)
+	}
+}
+
+func (c *CreatureModifier) record(action Action) {
+	if c.trace != nil && len(c.name) > 0 {
+		c.trace.Records = append(c.trace.Records, TraceRecord{c.name, action})
+	}
+}
+
+// <- No trace, no records. It's optional after all.
+
+// And when some modifier decides to stop the chain,
+// everything after it gets recorded as skipped.
+
+func (c *CreatureModifier) Skip() {
+	c.record(Skipped)
+	c.skipRest()
+}
+
+func (c *CreatureModifier) skipRest() {
+	if c.next != nil {
+		c.next.Skip()
+	}
+}
+
+// The order of the modifiers matters. Doubling the attack and then
+// adding one is not the same as adding one and then doubling it.
+// So we'd like to be able to take a modifier that's already in the
+// chain, and move it to the front of it, or to the back.
+
+// A flat bonus to the attack shows the difference nicely.
+
+type IncreaseAttackModifier struct {
+	CreatureModifier
+	Amount int
+}
+
+func NewIncreaseAttackModifier(c *Creature, amount int) *IncreaseAttackModifier {
+	return &IncreaseAttackModifier{
+		CreatureModifier{
+			creature: c,
+			name:     "IncreaseAttack",
+		},
+		amount,
+	}
+}
+
+func (i *IncreaseAttackModifier) Handle() {
+	fmt.Println("Increasing", i.creature.Name, "\b's attack by", i.Amount)
+	i.creature.Attack += i.Amount
+	i.record(Applied)
+	i.CreatureModifier.Handle()
+}
+
+// To relink the list we need to get at the next pointer of
+// every modifier, whatever its type. They all embed a CreatureModifier,
+// so that's what base() gives us, and the interface is what forces
+// every modifier to have one.
+
+func (c *CreatureModifier) base() *CreatureModifier {
+	return c
+}
+
+// Taking a modifier out means finding the one before it,
+// and pointing that one past it.
+
+func (c *CreatureModifier) unlink(m Modifier) bool {
+	for prev := c; prev.next != nil; prev = prev.next.base() {
+		if prev.next == m {
+			prev.next = m.base().next
+			m.base().next = nil
+			return true
+		}
+	}
+	return false
+}
+
+// And then we put it back in, either right after the root,
+// or after whatever is last.
+
+func (c *CreatureModifier) MoveToFront(m Modifier) bool {
+	if !c.unlink(m) {
+		return false
+	}
+	m.base().next = c.next
+	c.next = m
+	return true
+}
+
+func (c *CreatureModifier) MoveToBack(m Modifier) bool {
+	if !c.unlink(m) {
+		return false
+	}
+	last := c
+	for last.next != nil {
+		last = last.next.base()
+	}
+	last.next = m
+	return true
+}
+
+// <- Both are meant to be called on the root of the chain.
+//	  A modifier that isn't in the chain is left alone.
+
+// Bonus:
+// If we squint a little, there's nothing about creatures in
+// the way the chain works. Every link does something to a subject,
+// and then it either lets the next one go or it stops everything.
+
+// With generics, we can turn that into something reusable, where
+// a stage is just a function, and returning false halts the chain
+// exactly like the NoBufsModifier does.
+
+type Stage[T any] func(subject *T) bool
+
+type Pipeline[T any] struct {
+	stages []Stage[T]
+}
+
+func NewPipeline[T any](stages ...Stage[T]) *Pipeline[T] {
+	return &Pipeline[T]{stages}
+}
+
+func (p *Pipeline[T]) Then(stage Stage[T]) *Pipeline[T] {
+	p.stages = append(p.stages, stage)
+	return p
+}
+
+// <- Fluent, so stages can be chained together.
+
+func (p *Pipeline[T]) Run(subject *T) bool {
+	for _, stage := range p.stages {
+		if !stage(subject) {
+			return false
+		}
+	}
+	return true
+}
+
+// <- We find out whether the whole chain went through.
+
 // Recap:
 // -> This implementation of Chain of Responsibility is called Method Chain
 //	  because we're following a linked list of these modifiers and we're calling
@@ -168,6 +379,8 @@ func main() {
 	fmt.Println(goblin.String())
 
 	root := NewCreatureModifier(goblin)
+	trace := &Trace{}
+	root.SetTrace(trace)
 
 	root.Add(NewNoBufsModifier(goblin))
 
@@ -176,4 +389,31 @@ func main() {
 	root.Handle()
 
 	fmt.Println(goblin.String())
+	fmt.Print(trace)
+
+	orc := NewCreature("Orc", 3, 3)
+	completed := NewPipeline[Creature]().
+		Then(func(c *Creature) bool { c.Attack *= 2; return true }).
+		Then(func(c *Creature) bool { return false }). // <- no bufs
+		Then(func(c *Creature) bool { c.Defense++; return true }).
+		Run(orc)
+	fmt.Println(orc.String(), "completed:", completed)
+
+	troll := NewCreature("Troll", 2, 2)
+	chain := NewCreatureModifier(troll)
+	double := NewDoubleAttackModifier(troll)
+	chain.Add(NewIncreaseAttackModifier(troll, 1))
+	chain.Add(double)
+	chain.Handle()
+	fmt.Println(troll.String()) // (2+1)*2 = 6
+
+	troll.Attack = 2
+	chain.MoveToFront(double)
+	chain.Handle()
+	fmt.Println(troll.String()) // 2*2+1 = 5
+
+	troll.Attack = 2
+	chain.MoveToBack(double)
+	chain.Handle()
+	fmt.Println(troll.String()) // back to 6
 }
@@ -0,0 +1,95 @@
+package main
+
+import "testing"
+
+func TestTraceRecordsEveryDecision(t *testing.T) {
+	goblin := NewCreature("Goblin", 1, 1)
+	root := NewCreatureModifier(goblin)
+	trace := &Trace{}
+	root.SetTrace(trace)
+	root.Add(NewDoubleAttackModifier(goblin))
+	root.Add(NewIncreaseDefenseModifier(goblin))
+	root.Add(NewNoBufsModifier(goblin))
+	root.Add(NewIncreaseAttackModifier(goblin, 1))
+	root.Handle()
+
+	want := []TraceRecord{
+		{"DoubleAttack", Applied},
+		{"IncreaseDefense", Applied},
+		{"NoBufs", Blocked},
+		{"IncreaseAttack", Skipped},
+	}
+	if len(trace.Records) != len(want) {
+		t.Fatalf("got %v, want %v", trace.Records, want)
+	}
+	for i := range want {
+		if trace.Records[i] != want[i] {
+			t.Errorf("record %d: got %v, want %v", i, trace.Records[i], want[i])
+		}
+	}
+	if goblin.Attack != 2 || goblin.Defense != 2 {
+		t.Errorf("got %v", goblin)
+	}
+}
+
+func TestTraceRecordsSkippedCondition(t *testing.T) {
+	orc := NewCreature("Orc", 3, 3)
+	root := NewCreatureModifier(orc)
+	trace := &Trace{}
+	root.SetTrace(trace)
+	root.Add(NewIncreaseDefenseModifier(orc))
+	root.Handle()
+
+	if len(trace.Records) != 1 || trace.Records[0] != (TraceRecord{"IncreaseDefense", Skipped}) {
+		t.Fatalf("got %v", trace.Records)
+	}
+	if trace.String() != "IncreaseDefense: skipped\n" {
+		t.Fatalf("got %q", trace.String())
+	}
+}
+
+func TestPipelineStopsOnFalse(t *testing.T) {
+	orc := NewCreature("Orc", 3, 3)
+	completed := NewPipeline[Creature]().
+		Then(func(c *Creature) bool { c.Attack *= 2; return true }).
+		Then(func(c *Creature) bool { return false }).
+		Then(func(c *Creature) bool { c.Defense++; return true }).
+		Run(orc)
+	if completed || orc.Attack != 6 || orc.Defense != 3 {
+		t.Fatalf("completed %v, %v", completed, orc)
+	}
+
+	if !NewPipeline[int]().Run(new(int)) {
+		t.Fatal("an empty pipeline should complete")
+	}
+}
+
+func TestReorderingTheChain(t *testing.T) {
+	troll := NewCreature("Troll", 2, 2)
+	chain := NewCreatureModifier(troll)
+	double := NewDoubleAttackModifier(troll)
+	chain.Add(NewIncreaseAttackModifier(troll, 1))
+	chain.Add(double)
+
+	for _, step := range []struct {
+		move func() bool
+		want int
+	}{
+		{func() bool { return true }, 6},
+		{func() bool { return chain.MoveToFront(double) }, 5},
+		{func() bool { return chain.MoveToBack(double) }, 6},
+	} {
+		troll.Attack = 2
+		if !step.move() {
+			t.Fatal("the modifier was not found")
+		}
+		chain.Handle()
+		if troll.Attack != step.want {
+			t.Fatalf("attack %d, want %d", troll.Attack, step.want)
+		}
+	}
+
+	if chain.MoveToFront(NewDoubleAttackModifier(troll)) {
+		t.Fatal("moved a modifier which isn't in the chain")
+	}
+}
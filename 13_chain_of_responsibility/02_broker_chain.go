@@ -20,6 +20,8 @@ package main
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"sync"
 )
 
@@ -84,9 +86,12 @@ type Query struct {
 // Now we want to define a bunch of interfaces for implementing the Observer.3
 
 type Observer interface {
-	Handle(q *Query)
+	Handle(q *Query) (handled bool)
 }
 
+// <- Whoever handles a query can also decide that it's done with,
+//	  that it has been consumed, and nobody else should see it.
+
 type Observable interface {
 	Subscribe(o Observer)
 	Unsubscribe(o Observer)
@@ -98,13 +103,52 @@ type Observable interface {
 
 // Now we can finaly build our centralized component, the Game.
 
+// Since observers can stop the query from going any further,
+// the order in which they get it suddenly matters a lot.
+// So every subscription comes with a priority, and higher
+// priorities get to see the query first.
+
+type subscription struct {
+	observer Observer
+	priority int
+}
+
 type Game struct {
-	observers sync.Map
+	mu            sync.Mutex
+	subscriptions []subscription
+	creatures     map[string]*Creature
+}
+
+// <- The mutex is here because creatures and modifiers
+//	  could be coming and going from different goroutines.
+
+// The game also knows who's taking part in it, so that
+// one participant can ask about another one by name.
+
+func (g *Game) AddCreature(c *Creature) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.creatures == nil {
+		g.creatures = map[string]*Creature{}
+	}
+	g.creatures[c.Name] = c
+}
+
+func (g *Game) RemoveCreature(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.creatures, name)
 }
 
-// <- This syn map is going to allow us to basically keep a map
-//	  of every single subscriber and to iterate this map to go through
-// 	  every single subscriber and notify on that subscriber.
+func (g *Game) Creature(name string) (*Creature, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c, ok := g.creatures[name]
+	return c, ok
+}
 
 // Now that we have this, what we need to be able to do is we need
 // to implement the observable interface on the Game.
@@ -112,29 +156,59 @@ type Game struct {
 // going to be subscribed to.
 
 func (g *Game) Subscribe(o Observer) {
-	g.observers.Store(o, struct{}{})
+	g.SubscribeWithPriority(o, 0)
+}
+
+// Observers with the same priority are notified in the order
+// in which they subscribed, so we insert after all of them.
+
+func (g *Game) SubscribeWithPriority(o Observer, priority int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	i := sort.Search(len(g.subscriptions), func(i int) bool {
+		return g.subscriptions[i].priority < priority
+	})
+	g.subscriptions = append(g.subscriptions, subscription{})
+	copy(g.subscriptions[i+1:], g.subscriptions[i:])
+	g.subscriptions[i] = subscription{o, priority}
 }
 
 func (g *Game) Unsubscribe(o Observer) {
-	g.observers.Delete(o)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for i, s := range g.subscriptions {
+		if s.observer == o {
+			g.subscriptions = append(g.subscriptions[:i], g.subscriptions[i+1:]...)
+			return
+		}
+	}
 }
 
 func (g *Game) Fire(q *Query) {
-	g.observers.Range(func(key, value interface{}) bool {
-		if key == nil {
-			return false
-		}
+	g.mu.Lock()
+	subscriptions := append([]subscription(nil), g.subscriptions...)
+	g.mu.Unlock()
 
-		key.(Observer).Handle(q)
-		return true
-	})
+	for _, s := range subscriptions {
+		if s.observer.Handle(q) {
+			return
+		}
+	}
 }
 
+// <- We go through a copy, so an observer is free to
+//	  subscribe or unsubscribe while handling a query.
+
 // We can now make a constructor for our Crature,
 // whisch makes it easier to initilize our little hatchling creature.
 
 func NewCreature(game *Game, name string, attack, defense int) *Creature {
-	return &Creature{game: game, Name: name, attack: attack, defense: defense}
+	c := &Creature{game: game, Name: name, attack: attack, defense: defense}
+	game.AddCreature(c)
+
+	return c
 }
 
 // The idea is that we don't address attack and defense directly
@@ -174,8 +248,8 @@ type CreatureModifier struct {
 // This means that even though we can theoretically give it a Handle method,
 // and make it effectively an observer, there's really nothing to put in there.
 
-func (c *CreatureModifier) Handle(q *Query) {
-	// nothing !
+func (c *CreatureModifier) Handle(q *Query) bool {
+	return false // nothing !
 }
 
 // <- By default, this thing only exists so that we can compose it
@@ -187,10 +261,11 @@ type DoubleAttackModifier struct {
 
 // <- We also want a Handle method, because this needs to be an Observer.
 
-func (d *DoubleAttackModifier) Handle(q *Query) {
+func (d *DoubleAttackModifier) Handle(q *Query) bool {
 	if q.CreatureName == d.creature.Name && q.WhatToQuery == Attack {
 		q.Value *= 2
 	}
+	return false
 }
 
 // It's not optional for us to have a constructor, we absolutely
@@ -221,6 +296,148 @@ func (d *DoubleAttackModifier) Close() error {
 // The idea here is that we hace a method which can be used to unsubscribe
 // this particular modifier from the game events.
 
+// Now, what if the goblin steps into some anti-magic field?
+// No modifier should be able to touch its attack anymore.
+// With priorities that's easy, the field gets to see the query
+// before anybody else, and it simply consumes it.
+
+type AntiMagicField struct {
+	CreatureModifier
+}
+
+func (a *AntiMagicField) Handle(q *Query) bool {
+	return q.CreatureName == a.creature.Name && q.WhatToQuery == Attack
+}
+
+func NewAntiMagicField(g *Game, c *Creature) *AntiMagicField {
+	a := &AntiMagicField{CreatureModifier{g, c}}
+	g.SubscribeWithPriority(a, 100)
+
+	return a
+}
+
+func (a *AntiMagicField) Close() error {
+	a.game.Unsubscribe(a)
+	return nil
+}
+
+// Doubling is a bit crude. Most games scale things by a percentage,
+// like +50% attack, and then the result isn't always a whole number.
+// Since everything downstream works with ints, we need to decide how
+// to round, and we need to decide it up front, otherwise combining
+// modifiers gives us results nobody can predict.
+
+type Rounding int
+
+const (
+	Floor Rounding = iota // 4.5 -> 4, the default
+	Round                 // 4.5 -> 5, halves go away from zero
+	Ceil                  // 4.1 -> 5
+)
+
+func (r Rounding) apply(x float64) int {
+	switch r {
+	case Round:
+		return int(math.Round(x))
+	case Ceil:
+		return int(math.Ceil(x))
+	}
+	return int(math.Floor(x))
+}
+
+type PercentageAttackModifier struct {
+	CreatureModifier
+	Percent  int
+	Rounding Rounding
+}
+
+// <- The rounding happens every time this modifier is applied,
+//	  so with several modifiers, the order in which they subscribed
+//	  matters: +50% then +1 is not the same as +1 then +50%.
+
+func (p *PercentageAttackModifier) Handle(q *Query) bool {
+	if q.CreatureName == p.creature.Name && q.WhatToQuery == Attack {
+		q.Value = p.Rounding.apply(float64(q.Value) * float64(100+p.Percent) / 100)
+	}
+	return false
+}
+
+func NewPercentageAttackModifier(g *Game, c *Creature, percent int) *PercentageAttackModifier {
+	p := &PercentageAttackModifier{CreatureModifier: CreatureModifier{g, c}, Percent: percent}
+	g.Subscribe(p)
+
+	return p
+}
+
+func (p *PercentageAttackModifier) Close() error {
+	p.game.Unsubscribe(p)
+	return nil
+}
+
+// And to go with it, the simplest modifier of all, a flat bonus.
+
+type IncreaseAttackModifier struct {
+	CreatureModifier
+	Amount int
+}
+
+func (i *IncreaseAttackModifier) Handle(q *Query) bool {
+	if q.CreatureName == i.creature.Name && q.WhatToQuery == Attack {
+		q.Value += i.Amount
+	}
+	return false
+}
+
+func NewIncreaseAttackModifier(g *Game, c *Creature, amount int) *IncreaseAttackModifier {
+	i := &IncreaseAttackModifier{CreatureModifier{g, c}, amount}
+	g.Subscribe(i)
+
+	return i
+}
+
+func (i *IncreaseAttackModifier) Close() error {
+	i.game.Unsubscribe(i)
+	return nil
+}
+
+// So far every modifier only ever looked at its own creature.
+// But some effects depend on somebody else, say a goblin fights
+// better while its ally is still standing and can still defend itself.
+// The modifier doesn't hold on to the ally, it asks the game for it
+// every time, and the ally's defense goes through the very same chain.
+
+type AllyBonusModifier struct {
+	CreatureModifier
+	Ally   string
+	Amount int
+}
+
+func (a *AllyBonusModifier) Handle(q *Query) bool {
+	if q.CreatureName != a.creature.Name || q.WhatToQuery != Attack {
+		return false
+	}
+	if ally, ok := a.game.Creature(a.Ally); ok && ally.Defense() > 0 {
+		q.Value += a.Amount
+	}
+	return false
+}
+
+// <- Asking for the ally's defense fires a brand new query while we're
+//	  still handling this one. That's fine, Fire doesn't hold on to the
+//	  lock while observers are busy, and this modifier ignores defense queries.
+
+func NewAllyBonusModifier(g *Game, c *Creature, ally string, amount int) *AllyBonusModifier {
+	a := &AllyBonusModifier{CreatureModifier{g, c}, ally, amount}
+	g.Subscribe(a)
+
+	return a
+}
+
+func (a *AllyBonusModifier) Close() error {
+	a.game.Unsubscribe(a)
+	return nil
+}
+
 // Recap:
 // -> This has been much more sophisticated example of how we
 //	  would build a mediator with a chain of responsibility on top of it
@@ -232,7 +449,7 @@ func (d *DoubleAttackModifier) Close() error {
 // -> This is a more flexible implementation of the Chain of Responsibility
 
 func main() {
-	game := &Game{sync.Map{}} // the central mediator
+	game := &Game{} // the central mediator
 	goblin := NewCreature(game, "Stronk Goblin", 2, 2)
 	fmt.Println(goblin.String())
 
@@ -243,4 +460,29 @@ func main() {
 	}
 
 	fmt.Println(goblin.String())
+
+	{ // the field wins over the modifier, whatever order they came in
+		m := NewDoubleAttackModifier(game, goblin)
+		f := NewAntiMagicField(game, goblin)
+		fmt.Println(goblin.String())
+		f.Close()
+		m.Close()
+	}
+
+	orc := NewCreature(game, "Orc", 3, 3)
+	for _, rounding := range []Rounding{Floor, Round, Ceil} {
+		p := NewPercentageAttackModifier(game, orc, 50) // 3 * 1.5 = 4.5
+		p.Rounding = rounding
+		i := NewIncreaseAttackModifier(game, orc, 1)
+		fmt.Println(orc.String())
+		i.Close()
+		p.Close()
+	}
+
+	NewCreature(game, "Shaman", 1, 1)
+	b := NewAllyBonusModifier(game, goblin, "Shaman", 2)
+	fmt.Println("With the shaman around:", goblin.String())
+	game.RemoveCreature("Shaman")
+	fmt.Println("After the shaman is gone:", goblin.String())
+	b.Close()
 }
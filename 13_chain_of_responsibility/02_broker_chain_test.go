@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestModifierCloseRemovesIt(t *testing.T) {
+	game := &Game{}
+	goblin := NewCreature(game, "Goblin", 2, 2)
+	m := NewDoubleAttackModifier(game, goblin)
+	if goblin.Attack() != 4 {
+		t.Fatalf("attack %d, want 4", goblin.Attack())
+	}
+	m.Close()
+	if goblin.Attack() != 2 || goblin.Defense() != 2 {
+		t.Fatalf("after closing: %v", goblin)
+	}
+}
+
+func TestHigherPriorityStopsPropagation(t *testing.T) {
+	game := &Game{}
+	goblin := NewCreature(game, "Goblin", 2, 2)
+	orc := NewCreature(game, "Orc", 3, 3)
+
+	NewDoubleAttackModifier(game, goblin)
+	NewDoubleAttackModifier(game, orc)
+	f := NewAntiMagicField(game, goblin) // <- subscribed last, runs first
+	if goblin.Attack() != 2 {
+		t.Fatalf("attack %d, the field should stop the modifier", goblin.Attack())
+	}
+	if orc.Attack() != 6 {
+		t.Fatalf("orc attack %d, the field only covers the goblin", orc.Attack())
+	}
+	f.Close()
+	if goblin.Attack() != 4 {
+		t.Fatalf("attack %d after the field is gone", goblin.Attack())
+	}
+}
+
+func TestSubscriptionsOrderedByPriority(t *testing.T) {
+	game := &Game{}
+	orc := NewCreature(game, "Orc", 3, 3)
+	double := &DoubleAttackModifier{CreatureModifier{game, orc}}
+	increase := &IncreaseAttackModifier{CreatureModifier{game, orc}, 1}
+
+	game.SubscribeWithPriority(double, 0)
+	game.SubscribeWithPriority(increase, 10)
+	if orc.Attack() != 8 { // (3+1)*2
+		t.Fatalf("attack %d, want 8", orc.Attack())
+	}
+}
+
+func TestPercentageRounding(t *testing.T) {
+	tests := map[Rounding]int{Floor: 5, Round: 6, Ceil: 6}
+	for rounding, want := range tests {
+		game := &Game{}
+		orc := NewCreature(game, "Orc", 3, 3)
+		p := NewPercentageAttackModifier(game, orc, 50) // 3 * 1.5 = 4.5
+		p.Rounding = rounding
+		NewIncreaseAttackModifier(game, orc, 1)
+		if orc.Attack() != want {
+			t.Errorf("rounding %d: attack %d, want %d", rounding, orc.Attack(), want)
+		}
+	}
+
+	if got := Round.apply(-4.5); got != -5 {
+		t.Errorf("Round(-4.5) = %d, want -5", got)
+	}
+}
+
+func TestAllyBonusDependsOnAlly(t *testing.T) {
+	game := &Game{}
+	goblin := NewCreature(game, "Goblin", 2, 2)
+	NewAllyBonusModifier(game, goblin, "Shaman", 2)
+	if goblin.Attack() != 2 {
+		t.Fatalf("attack %d without the shaman", goblin.Attack())
+	}
+
+	shaman := NewCreature(game, "Shaman", 1, 1)
+	if goblin.Attack() != 4 {
+		t.Fatalf("attack %d with the shaman", goblin.Attack())
+	}
+
+	shaman.defense = 0
+	if goblin.Attack() != 2 {
+		t.Fatalf("attack %d with a defenseless shaman", goblin.Attack())
+	}
+
+	shaman.defense = 1
+	game.RemoveCreature("Shaman")
+	if goblin.Attack() != 2 {
+		t.Fatalf("attack %d after the shaman is gone", goblin.Attack())
+	}
+}
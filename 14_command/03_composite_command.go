@@ -20,14 +20,25 @@
 
 package main
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 var overdraftLimit = -500
 
 type BankAccount struct {
+	ID      string
 	balance int
 }
 
+// <- The ID only matters once we start saving commands, see the end.
+
 func (b *BankAccount) Deposit(amount int) {
 	b.balance += amount
 	fmt.Println("Depositetd: ", amount, "\b, balance ist now ", b.balance)
@@ -67,10 +78,12 @@ const (
 )
 
 type BankAccountCommand struct {
-	account   *BankAccount
-	action    Action
-	amount    int
-	succeeded bool
+	account      *BankAccount
+	action       Action
+	amount       int
+	succeeded    bool
+	meta         CommandMeta
+	compensation Command
 }
 
 // <- Down here, we once again need to implement the command interface.
@@ -106,7 +119,19 @@ func (b *BankAccountCommand) Undo() {
 }
 
 func NewBankAccountCommand(account *BankAccount, action Action, amount int) *BankAccountCommand {
-	return &BankAccountCommand{account: account, action: action, amount: amount}
+	var description string
+	switch action {
+	case Deposit:
+		description = fmt.Sprintf("Deposit %d to account", amount)
+	case Withdraw:
+		description = fmt.Sprintf("Withdraw %d from account", amount)
+	}
+	return &BankAccountCommand{
+		account: account,
+		action:  action,
+		amount:  amount,
+		meta:    newCommandMeta(description),
+	}
 }
 
 // Now we can go ahead and we can build a composite bank accounts command.
@@ -115,6 +140,7 @@ func NewBankAccountCommand(account *BankAccount, action Action, amount int) *Ban
 
 type CompositeBankAccountCommand struct {
 	commands []Command
+	meta     CommandMeta
 }
 
 // <- We also need to implement the Command interface.
@@ -129,10 +155,12 @@ func (c CompositeBankAccountCommand) Call() {
 
 func (c CompositeBankAccountCommand) Undo() {
 	for i := range c.commands {
-		c.commands[len(c.commands)-i-1].Undo()
+		undo(c.commands[len(c.commands)-i-1])
 	}
 }
 
+// <- What undo() does exactly, we'll see at the end.
+
 func (c CompositeBankAccountCommand) Succeeded() bool {
 	for _, cmd := range c.commands {
 		if !cmd.Succeeded() {
@@ -165,6 +193,7 @@ type MoneyTransferCommand struct {
 
 func NewMoneyTransferCommand(from, to *BankAccount, amount int) *MoneyTransferCommand {
 	c := &MoneyTransferCommand{from: from, to: to, amount: amount}
+	c.meta = newCommandMeta(fmt.Sprintf("Transfer %d between accounts", amount))
 	c.commands = append(c.commands, NewBankAccountCommand(from, Withdraw, amount))
 	c.commands = append(c.commands, NewBankAccountCommand(to, Deposit, amount))
 
@@ -194,6 +223,445 @@ func (m *MoneyTransferCommand) Call() {
 
 // We can kind of do symmetrical operations, so our Undo will also work.
 
+// Bonus:
+// One of the big benefits of commands is that they're objects,
+// so we can keep them around and later on see what actually happened.
+// But for that, a command has to be able to tell us something about itself.
+
+type CommandMeta struct {
+	ID          int
+	CreatedAt   time.Time
+	Description string
+}
+
+var lastCommandID atomic.Int64
+
+func newCommandMeta(description string) CommandMeta {
+	return CommandMeta{int(lastCommandID.Add(1)), time.Now(), description}
+}
+
+// <- Commands can be made from several goroutines at once,
+//	  so handing out the next ID has to be atomic.
+
+// Not every command has to do this, so rather than adding it
+// to the Command interface we have a separate, optional one.
+
+type MetadataProvider interface {
+	Metadata() CommandMeta
+}
+
+func (b *BankAccountCommand) Metadata() CommandMeta {
+	return b.meta
+}
+
+func (c CompositeBankAccountCommand) Metadata() CommandMeta {
+	return c.meta
+}
+
+// <- And a composite can also tell us about every part of it.
+
+func (c CompositeBankAccountCommand) SubMetadata() []CommandMeta {
+	var result []CommandMeta
+	for _, cmd := range c.commands {
+		if m, ok := cmd.(MetadataProvider); ok {
+			result = append(result, m.Metadata())
+		}
+	}
+	return result
+}
+
+// Now we can have a history which calls the commands for us
+// and keeps a human readable log of everything that was executed.
+
+type CommandHistory struct {
+	entries []string
+}
+
+func (h *CommandHistory) Execute(cmd Command) {
+	cmd.Call()
+
+	m, ok := cmd.(MetadataProvider)
+	if !ok {
+		h.entries = append(h.entries, "<unknown command>")
+		return
+	}
+	meta := m.Metadata()
+	h.entries = append(h.entries, fmt.Sprintf("#%d %s %s (succeeded: %t)",
+		meta.ID, meta.CreatedAt.Format(time.TimeOnly), meta.Description, cmd.Succeeded()))
+
+	if c, ok := cmd.(interface{ SubMetadata() []CommandMeta }); ok {
+		for _, sub := range c.SubMetadata() {
+			h.entries = append(h.entries, fmt.Sprintf("  #%d %s", sub.ID, sub.Description))
+		}
+	}
+}
+
+func (h *CommandHistory) String() string {
+	return strings.Join(h.entries, "\n")
+}
+
+// And one more thing. The money transfer makes sure that we don't
+// deposit the money if the withdrawal failed, but if something fails
+// half way through, it's still up to us to remember to call Undo().
+
+// What we really want is all or nothing in a single call.
+// So, a transaction runs the commands one after another, and the
+// moment one of them fails, it undoes everything that succeeded
+// before it, in reverse order, and tells us about it.
+
+type TransactionalCommand struct {
+	CompositeBankAccountCommand
+}
+
+func NewTransactionalCommand(commands ...Command) *TransactionalCommand {
+	t := &TransactionalCommand{}
+	t.commands = commands
+	t.meta = newCommandMeta(fmt.Sprintf("Transaction of %d commands", len(commands)))
+	return t
+}
+
+func (t *TransactionalCommand) Execute() error {
+	for i, cmd := range t.commands {
+		cmd.Call()
+		if cmd.Succeeded() {
+			continue
+		}
+
+		for j := i - 1; j >= 0; j-- {
+			undo(t.commands[j])
+			t.commands[j].SetSucceeded(false)
+		}
+
+		description := "command"
+		if m, ok := cmd.(MetadataProvider); ok {
+			description = m.Metadata().Description
+		}
+		return fmt.Errorf("transaction rolled back: %s failed", description)
+	}
+	return nil
+}
+
+// <- Most of the time, though, a transaction gets handed to somebody
+//	  who only knows about the Command interface, like the history or
+//	  an executor, and they call Call(). The embedded composite's Call()
+//	  would simply run everything, so we route it through Execute() too.
+
+func (t *TransactionalCommand) Call() {
+	t.Execute()
+}
+
+// <- The error is gone, but Succeeded() still tells whether it worked.
+
+// Sometimes we don't want to run every command the moment
+// it arrives. Think of writes to a database, where it's a lot
+// cheaper to send a whole bunch of them in one go.
+
+// So a batch collects the commands, and once there are enough
+// of them, it runs them all as a single composite command.
+
+type BatchCommand struct {
+	Size    int
+	pending []Command
+}
+
+func NewBatchCommand(size int) *BatchCommand {
+	return &BatchCommand{Size: size}
+}
+
+// Adding a command returns the composite that was executed,
+// if this command happened to fill up the batch, and nil otherwise.
+
+func (b *BatchCommand) Add(cmd Command) *CompositeBankAccountCommand {
+	b.pending = append(b.pending, cmd)
+	if len(b.pending) < b.Size {
+		return nil
+	}
+	return b.Flush()
+}
+
+// And of course, we have to be able to run whatever is left,
+// even if the batch isn't full yet.
+
+func (b *BatchCommand) Flush() *CompositeBankAccountCommand {
+	if len(b.pending) == 0 {
+		return nil
+	}
+	batch := &CompositeBankAccountCommand{
+		commands: b.pending,
+		meta:     newCommandMeta(fmt.Sprintf("Batch of %d commands", len(b.pending))),
+	}
+	b.pending = nil
+	batch.Call()
+	return batch
+}
+
+// <- The executed composite can be asked whether everything
+//	  succeeded, and it can be undone as a whole.
+
+// And since commands are just data, we can also write them down.
+// If a queue of pending commands is saved somewhere before being
+// executed, then after a crash we can load it back and replay it,
+// which is pretty much what a write-ahead log does.
+
+// The only thing we can't write down is the pointer to the account,
+// it means nothing in the next run of the program. So instead we
+// write down the account's ID, and look it up again when loading.
+
+type commandRecord struct {
+	Kind     string          `json:"kind,omitempty"`
+	Account  string          `json:"account,omitempty"`
+	Action   Action          `json:"action"`
+	Amount   int             `json:"amount,omitempty"`
+	Commands []commandRecord `json:"commands,omitempty"`
+}
+
+// <- A record with sub-commands is a composite, anything else
+//	  is a single bank account command. The action is always written,
+//	  even a Deposit, which happens to be the zero value.
+
+// A money transfer is a composite as well, but one with its own
+// Call(), which stops after a failed withdrawal. If it came back as
+// a plain composite, replaying it would deposit money that was never
+// withdrawn, so the record also says what kind of composite it was.
+
+const transferKind = "transfer"
+
+type recordable interface {
+	record() (commandRecord, error)
+}
+
+func (b *BankAccountCommand) record() (commandRecord, error) {
+	if len(b.account.ID) == 0 {
+		return commandRecord{}, fmt.Errorf("cannot save a command for an account without an ID")
+	}
+	return commandRecord{Account: b.account.ID, Action: b.action, Amount: b.amount}, nil
+}
+
+func (c CompositeBankAccountCommand) record() (commandRecord, error) {
+	result := commandRecord{}
+	for _, cmd := range c.commands {
+		r, ok := cmd.(recordable)
+		if !ok {
+			return commandRecord{}, fmt.Errorf("cannot save a command of type %T", cmd)
+		}
+		sub, err := r.record()
+		if err != nil {
+			return commandRecord{}, err
+		}
+		result.Commands = append(result.Commands, sub)
+	}
+	return result, nil
+}
+
+func (m *MoneyTransferCommand) record() (commandRecord, error) {
+	rec, err := m.CompositeBankAccountCommand.record()
+	rec.Kind = transferKind
+	return rec, err
+}
+
+func MarshalCommand(cmd Command) ([]byte, error) {
+	r, ok := cmd.(recordable)
+	if !ok {
+		return nil, fmt.Errorf("cannot save a command of type %T", cmd)
+	}
+	rec, err := r.record()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(rec)
+}
+
+// When loading, the caller tells us which accounts exist.
+// Whatever we get back is a fresh command that hasn't been called yet.
+
+func UnmarshalCommand(data []byte, accounts map[string]*BankAccount) (Command, error) {
+	var rec commandRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return rec.command(accounts)
+}
+
+func (r commandRecord) command(accounts map[string]*BankAccount) (Command, error) {
+	if len(r.Commands) == 0 {
+		account, ok := accounts[r.Account]
+		if !ok {
+			return nil, fmt.Errorf("unknown account %q", r.Account)
+		}
+		return NewBankAccountCommand(account, r.Action, r.Amount), nil
+	}
+	if r.Kind == transferKind {
+		return r.transfer(accounts)
+	}
+
+	c := &CompositeBankAccountCommand{}
+	for _, sub := range r.Commands {
+		cmd, err := sub.command(accounts)
+		if err != nil {
+			return nil, err
+		}
+		c.commands = append(c.commands, cmd)
+	}
+	c.meta = newCommandMeta(fmt.Sprintf("Replay of %d commands", len(c.commands)))
+	return c, nil
+}
+
+// A transfer is rebuilt through its factory function, so it gets
+// its Call() back, but only if the record actually looks like one.
+
+func (r commandRecord) transfer(accounts map[string]*BankAccount) (Command, error) {
+	if len(r.Commands) != 2 ||
+		r.Commands[0].Action != Withdraw || r.Commands[1].Action != Deposit ||
+		r.Commands[0].Amount != r.Commands[1].Amount {
+		return nil, fmt.Errorf("malformed transfer record")
+	}
+	from, ok := accounts[r.Commands[0].Account]
+	if !ok {
+		return nil, fmt.Errorf("unknown account %q", r.Commands[0].Account)
+	}
+	to, ok := accounts[r.Commands[1].Account]
+	if !ok {
+		return nil, fmt.Errorf("unknown account %q", r.Commands[1].Account)
+	}
+	return NewMoneyTransferCommand(from, to, r.Commands[0].Amount), nil
+}
+
+// Not everything can be undone by simply doing the opposite.
+// Say a deposit triggered a fee. Withdrawing the deposited amount
+// leaves the account short by the fee, so what actually needs to
+// happen is something else, a compensation, which whoever knows
+// about the fee has to provide.
+
+type Compensable interface {
+	Compensation() Command
+}
+
+func (b *BankAccountCommand) SetCompensation(c Command) {
+	b.compensation = c
+}
+
+func (b *BankAccountCommand) Compensation() Command {
+	return b.compensation
+}
+
+// Whenever we undo a command, if it has a compensation, we run
+// that instead. This is pretty much how a saga works, every step
+// comes with its own way of being taken back.
+
+func undo(cmd Command) {
+	if c, ok := cmd.(Compensable); ok && c.Compensation() != nil {
+		if cmd.Succeeded() {
+			c.Compensation().Call()
+			cmd.SetSucceeded(false)
+		}
+		return
+	}
+	cmd.Undo()
+}
+
+// <- A command that never succeeded has nothing to compensate.
+
+// Commands are often just requests to somebody else, say the
+// API of an actual bank, and that somebody only lets us make so many
+// requests per second. Since commands are objects we can hold on to,
+// we can also hold them back until we're allowed to make them.
+
+// The usual way of doing this is a token bucket. The bucket holds
+// up to some number of tokens, every command takes one, and the
+// bucket slowly fills back up with time.
+
+// To check this without waiting around, time comes from a clock,
+// and so does the waiting itself.
+
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+// When the bucket is empty, we either wait for the next token,
+// or refuse to execute the command at all.
+
+type LimitPolicy int
+
+const (
+	Wait LimitPolicy = iota
+	Reject
+)
+
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+type RateLimitedExecutor struct {
+	mu        sync.Mutex
+	perSecond int
+	policy    LimitPolicy
+	clock     Clock
+	tokens    float64
+	last      time.Time
+}
+
+func NewRateLimitedExecutor(perSecond int, policy LimitPolicy, clock Clock) *RateLimitedExecutor {
+	if perSecond <= 0 {
+		panic("rate limit must allow at least one command per second")
+	}
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &RateLimitedExecutor{
+		perSecond: perSecond,
+		policy:    policy,
+		clock:     clock,
+		tokens:    float64(perSecond),
+		last:      clock.Now(),
+	}
+}
+
+// <- The bucket starts out full, so up to perSecond commands
+//	  can go through right away.
+
+func (r *RateLimitedExecutor) refill() {
+	now := r.clock.Now()
+	r.tokens += now.Sub(r.last).Seconds() * float64(r.perSecond)
+	r.tokens = min(r.tokens, float64(r.perSecond))
+	r.last = now
+}
+
+func (r *RateLimitedExecutor) Execute(cmd Command) error {
+	for {
+		r.mu.Lock()
+		r.refill()
+		if r.tokens >= 1 {
+			r.tokens--
+			r.mu.Unlock()
+			break
+		}
+		if r.policy == Reject {
+			r.mu.Unlock()
+			return ErrRateLimited
+		}
+		missing := (1 - r.tokens) / float64(r.perSecond)
+		r.mu.Unlock()
+		r.clock.Sleep(time.Duration(missing * float64(time.Second)))
+	}
+
+	cmd.Call()
+	return nil
+}
+
+// <- Whoever is waiting for a token lets go of the lock before
+//	  sleeping, and once they wake up they have to check the bucket
+//	  all over again, since somebody else might have been quicker.
+//	  The command itself runs outside of the lock as well.
+
 // Recap:
 // -> If we have operations that we need to handle as a single operation
 //	  we aggregate the composite command to get basically just the list
@@ -202,8 +670,8 @@ func (m *MoneyTransferCommand) Call() {
 // -> Composite command pattern easily extends command pattern
 
 func main() {
-	from := BankAccount{100}
-	to := BankAccount{0}
+	from := BankAccount{ID: "from", balance: 100}
+	to := BankAccount{ID: "to", balance: 0}
 
 	mtc := NewMoneyTransferCommand(&from, &to, 25)
 	mtc.Call()
@@ -211,4 +679,68 @@ func main() {
 
 	mtc.Undo()
 	fmt.Println(from, to)
+
+	history := CommandHistory{}
+	history.Execute(NewMoneyTransferCommand(&from, &to, 50))
+	fmt.Println(history.String())
+
+	tx := NewTransactionalCommand(
+		NewBankAccountCommand(&to, Deposit, 10),
+		NewBankAccountCommand(&from, Withdraw, 1000),
+	)
+	if err := tx.Execute(); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println(from, to)
+
+	batch := NewBatchCommand(2)
+	fmt.Println("Executed:", batch.Add(NewBankAccountCommand(&to, Deposit, 1)) != nil)
+	if done := batch.Add(NewBankAccountCommand(&to, Deposit, 2)); done != nil {
+		fmt.Println("Batch of two succeeded:", done.Succeeded())
+	}
+	batch.Add(NewBankAccountCommand(&to, Deposit, 3))
+	if done := batch.Flush(); done != nil {
+		fmt.Println("Partial batch:", done.Metadata().Description)
+	}
+
+	data, err := MarshalCommand(NewMoneyTransferCommand(&from, &to, 10))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(string(data))
+	// ... crash, restart ...
+	replayed, err := UnmarshalCommand(data, map[string]*BankAccount{"from": &from, "to": &to})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	replayed.Call()
+	fmt.Println(from, to)
+
+	// the deposit costs a fee of 5, so taking it back only takes back 95
+	fee := 5
+	deposit := NewBankAccountCommand(&to, Deposit, 100)
+	deposit.SetCompensation(NewBankAccountCommand(&to, Withdraw, 100-fee))
+	saga := CompositeBankAccountCommand{commands: []Command{deposit}}
+	saga.Call()
+	to.balance -= fee
+	saga.Undo()
+	fmt.Println(to)
+
+	limited := NewRateLimitedExecutor(5, Reject, nil)
+	for i := 1; i <= 6; i++ {
+		if err := limited.Execute(NewBankAccountCommand(&to, Deposit, 1)); err != nil {
+			fmt.Println("Deposit", i, "failed:", err)
+		}
+	}
+	time.Sleep(time.Second / 5)
+	fmt.Println("A fifth of a second later:", limited.Execute(NewBankAccountCommand(&to, Deposit, 1)))
+
+	start := time.Now()
+	patient := NewRateLimitedExecutor(5, Wait, nil)
+	for i := 0; i < 6; i++ {
+		patient.Execute(NewBankAccountCommand(&to, Deposit, 1))
+	}
+	fmt.Println("Six deposits with five tokens took", time.Since(start).Round(time.Second/10))
 }
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCommandMetadata(t *testing.T) {
+	account := &BankAccount{ID: "a"}
+	first := NewBankAccountCommand(account, Deposit, 10)
+	second := NewBankAccountCommand(account, Withdraw, 5)
+
+	if first.Metadata().ID >= second.Metadata().ID {
+		t.Errorf("IDs %d and %d are not increasing", first.Metadata().ID, second.Metadata().ID)
+	}
+	if first.Metadata().Description != "Deposit 10 to account" || first.Metadata().CreatedAt.IsZero() {
+		t.Errorf("got %+v", first.Metadata())
+	}
+
+	transfer := NewMoneyTransferCommand(account, &BankAccount{ID: "b"}, 25)
+	if subs := transfer.SubMetadata(); len(subs) != 2 || subs[0].Description != "Withdraw 25 from account" {
+		t.Errorf("sub metadata: %+v", subs)
+	}
+
+	history := CommandHistory{}
+	history.Execute(transfer)
+	lines := strings.Split(history.String(), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], "Transfer 25 between accounts (succeeded: true)") {
+		t.Errorf("history:\n%s", history.String())
+	}
+}
+
+func TestCommandIDsAreUniqueAcrossGoroutines(t *testing.T) {
+	ids := make([]int, 100)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids[i] = NewBankAccountCommand(&BankAccount{}, Deposit, 1).Metadata().ID
+		}()
+	}
+	wg.Wait()
+
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			t.Fatalf("ID %d was handed out twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestTransactionRollsBack(t *testing.T) {
+	from := &BankAccount{ID: "from", balance: 100}
+	to := &BankAccount{ID: "to"}
+	deposit := NewBankAccountCommand(to, Deposit, 10)
+	tx := NewTransactionalCommand(deposit, NewBankAccountCommand(from, Withdraw, 1000))
+
+	if err := tx.Execute(); err == nil || !strings.Contains(err.Error(), "Withdraw 1000") {
+		t.Fatalf("got %v", err)
+	}
+	if from.balance != 100 || to.balance != 0 || deposit.Succeeded() {
+		t.Fatalf("not rolled back: %d, %d, %v", from.balance, to.balance, deposit.Succeeded())
+	}
+
+	tx = NewTransactionalCommand(deposit, NewBankAccountCommand(from, Withdraw, 10))
+	if err := tx.Execute(); err != nil || from.balance != 90 || to.balance != 10 {
+		t.Fatalf("got %v, %d, %d", err, from.balance, to.balance)
+	}
+}
+
+func TestTransactionRollsBackWhenCalled(t *testing.T) {
+	from := &BankAccount{ID: "from", balance: 100}
+	to := &BankAccount{ID: "to"}
+	tx := NewTransactionalCommand(
+		NewBankAccountCommand(to, Deposit, 10),
+		NewBankAccountCommand(from, Withdraw, 1000),
+	)
+
+	history := CommandHistory{}
+	history.Execute(tx)
+	if from.balance != 100 || to.balance != 0 || tx.Succeeded() {
+		t.Fatalf("not rolled back: %d, %d, %v", from.balance, to.balance, tx.Succeeded())
+	}
+}
+
+func TestBatchFlushesWhenFull(t *testing.T) {
+	account := &BankAccount{ID: "a"}
+	batch := NewBatchCommand(2)
+
+	if done := batch.Add(NewBankAccountCommand(account, Deposit, 1)); done != nil || account.balance != 0 {
+		t.Fatal("a batch of one was executed")
+	}
+	done := batch.Add(NewBankAccountCommand(account, Deposit, 2))
+	if done == nil || !done.Succeeded() || account.balance != 3 {
+		t.Fatalf("the full batch was not executed: %d", account.balance)
+	}
+
+	batch.Add(NewBankAccountCommand(account, Deposit, 3))
+	if done := batch.Flush(); done == nil || done.Metadata().Description != "Batch of 1 commands" {
+		t.Fatal("the partial batch was not flushed")
+	}
+	if batch.Flush() != nil || account.balance != 6 {
+		t.Fatalf("flushed an empty batch: %d", account.balance)
+	}
+}
+
+func TestMarshalAndReplay(t *testing.T) {
+	from := &BankAccount{ID: "from", balance: 100}
+	to := &BankAccount{ID: "to"}
+	data, err := MarshalCommand(NewMoneyTransferCommand(from, to, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := UnmarshalCommand(data, map[string]*BankAccount{"from": from, "to": to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed.Call()
+	if from.balance != 90 || to.balance != 10 {
+		t.Fatalf("got %d, %d", from.balance, to.balance)
+	}
+
+	if _, err := UnmarshalCommand(data, map[string]*BankAccount{"from": from}); err == nil {
+		t.Error("replayed a command for an unknown account")
+	}
+	if _, err := MarshalCommand(NewBankAccountCommand(&BankAccount{}, Deposit, 1)); err == nil {
+		t.Error("saved a command for an account without an ID")
+	}
+	if _, err := MarshalCommand(&lockedCommand{&sync.Mutex{}, NewBankAccountCommand(from, Deposit, 1)}); err == nil {
+		t.Error("saved a command of an unknown type")
+	}
+}
+
+func TestReplayedTransferStopsAfterFailedWithdrawal(t *testing.T) {
+	from := &BankAccount{ID: "from"}
+	to := &BankAccount{ID: "to"}
+	data, err := MarshalCommand(NewMoneyTransferCommand(from, to, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := UnmarshalCommand(data, map[string]*BankAccount{"from": from, "to": to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := replayed.(*MoneyTransferCommand); !ok {
+		t.Fatalf("replayed a %T, want a transfer", replayed)
+	}
+	replayed.Call()
+	if from.balance != 0 || to.balance != 0 || replayed.Succeeded() {
+		t.Fatalf("got %d, %d, %v", from.balance, to.balance, replayed.Succeeded())
+	}
+
+	broken := `{"kind":"transfer","commands":[{"account":"to","action":0,"amount":5}]}`
+	if _, err := UnmarshalCommand([]byte(broken), map[string]*BankAccount{"to": to}); err == nil {
+		t.Error("replayed a transfer which only deposits")
+	}
+}
+
+func TestDepositActionIsSaved(t *testing.T) {
+	data, err := MarshalCommand(NewBankAccountCommand(&BankAccount{ID: "a"}, Deposit, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"action":0`) {
+		t.Fatalf("the deposit action is missing from %s", data)
+	}
+}
+
+func TestCompensationUndo(t *testing.T) {
+	to := &BankAccount{ID: "to"}
+	deposit := NewBankAccountCommand(to, Deposit, 100)
+	deposit.SetCompensation(NewBankAccountCommand(to, Withdraw, 95))
+	saga := CompositeBankAccountCommand{commands: []Command{deposit}}
+
+	saga.Call()
+	to.balance -= 5
+	saga.Undo()
+	if to.balance != 0 || deposit.Succeeded() {
+		t.Fatalf("got %d, %v", to.balance, deposit.Succeeded())
+	}
+	saga.Undo()
+	if to.balance != 0 {
+		t.Fatalf("compensated twice: %d", to.balance)
+	}
+}
+
+// A clock we control, which doesn't actually sleep,
+// it just moves its own time forward.
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Sleep(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func TestRateLimitedExecutorRejectsOverLimit(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	account := &BankAccount{ID: "to"}
+	limited := NewRateLimitedExecutor(5, Reject, clock)
+
+	for i := 1; i <= 5; i++ {
+		if err := limited.Execute(NewBankAccountCommand(account, Deposit, 1)); err != nil {
+			t.Fatalf("deposit %d: %v", i, err)
+		}
+	}
+	if err := limited.Execute(NewBankAccountCommand(account, Deposit, 1)); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("deposit 6: got %v, want ErrRateLimited", err)
+	}
+	if account.balance != 5 {
+		t.Fatalf("balance = %d, want 5", account.balance)
+	}
+
+	clock.Sleep(time.Second)
+	if err := limited.Execute(NewBankAccountCommand(account, Deposit, 1)); err != nil {
+		t.Fatalf("a second later: %v", err)
+	}
+}
+
+func TestRateLimitedExecutorWaitsForToken(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	account := &BankAccount{ID: "to"}
+	patient := NewRateLimitedExecutor(5, Wait, clock)
+
+	start := clock.Now()
+	for i := 0; i < 6; i++ {
+		if err := patient.Execute(NewBankAccountCommand(account, Deposit, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if waited := clock.Now().Sub(start); waited != time.Second/5 {
+		t.Fatalf("waited %v, want %v", waited, time.Second/5)
+	}
+	if account.balance != 6 {
+		t.Fatalf("balance = %d, want 6", account.balance)
+	}
+}
+
+// A clock whose Sleep blocks until the test lets it go, so we can
+// see how many callers are asleep at the same time.
+
+type blockingClock struct {
+	fakeClock
+	asleep chan struct{}
+	wake   chan struct{}
+}
+
+func (b *blockingClock) Sleep(d time.Duration) {
+	b.asleep <- struct{}{}
+	<-b.wake
+	b.fakeClock.Sleep(d)
+}
+
+func TestRateLimitedExecutorSleepsWithoutLock(t *testing.T) {
+	clock := &blockingClock{
+		fakeClock: fakeClock{now: time.Now()},
+		asleep:    make(chan struct{}),
+		wake:      make(chan struct{}),
+	}
+	var mu sync.Mutex
+	account := &BankAccount{ID: "to"}
+	deposit := &lockedCommand{&mu, NewBankAccountCommand(account, Deposit, 1)}
+	patient := NewRateLimitedExecutor(1, Wait, clock)
+	patient.Execute(deposit)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			patient.Execute(deposit)
+		}()
+	}
+
+	// both of them only get here if neither sleeps holding the lock
+	<-clock.asleep
+	<-clock.asleep
+	close(clock.wake)
+	go func() {
+		for range clock.asleep {
+		}
+	}()
+	wg.Wait()
+	close(clock.asleep)
+
+	if account.balance != 3 {
+		t.Fatalf("balance = %d, want 3", account.balance)
+	}
+}
+
+// The deposits themselves run concurrently, so they take turns
+// on the account.
+
+type lockedCommand struct {
+	mu *sync.Mutex
+	Command
+}
+
+func (l *lockedCommand) Call() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Command.Call()
+}
+
+func TestNewRateLimitedExecutorRejectsZeroRate(t *testing.T) {
+	for _, perSecond := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("perSecond %d: expected a panic", perSecond)
+				}
+			}()
+			NewRateLimitedExecutor(perSecond, Wait, nil)
+		}()
+	}
+}
@@ -11,7 +11,12 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"strconv"
 	"strings"
 	"unicode"
@@ -56,6 +61,9 @@ type Operation int
 const (
 	Addition Operation = iota
 	Substraction
+	Multiplication
+	Division
+	Power
 )
 
 type BinaryOperation struct {
@@ -73,76 +81,461 @@ func (b *BinaryOperation) Value() int {
 		return b.Left.Value() + b.Right.Value()
 	case Substraction:
 		return b.Left.Value() - b.Right.Value()
+	case Multiplication:
+		return b.Left.Value() * b.Right.Value()
+	case Division:
+		return b.Left.Value() / b.Right.Value()
+	case Power:
+		return power(b.Left.Value(), b.Right.Value())
 	default:
 		panic("Unsupported operation")
 	}
 }
 
+// We only deal in integers, so there are a few things to keep in mind:
+// -> division rounds towards zero, and dividing by zero panics
+// -> a negative exponent gives us 0, since the result would be a fraction
+//	  (except for a base of 1 or -1, which we don't bother with)
+// -> just like everywhere else in Go, a result that doesn't fit
+//	  into an int silently wraps around, unless we ask for a checked
+//	  evaluation, more on that further down
+
+// Multiplying the base by itself exponent times would take forever
+// for something like 1^9000000000000000000, so we square instead.
+// Every bit of the exponent either multiplies the result by the current
+// base or not, and the base gets squared on the way, so it only takes
+// as many steps as the exponent has bits.
+
+func power(base, exponent int) int {
+	result, _ := powerWith(base, exponent, func(a, b int) (int, error) {
+		return a * b, nil
+	})
+	return result
+}
+
+// The multiplication is passed in, so that the checked evaluation
+// further down can use the very same loop, only with a multiplication
+// which notices overflow.
+
+func powerWith(base, exponent int, mul func(a, b int) (int, error)) (int, error) {
+	if exponent < 0 {
+		return 0, nil // <- documented above, the fraction rounds towards zero
+	}
+	result := 1
+	for {
+		var err error
+		if exponent&1 == 1 {
+			if result, err = mul(result, base); err != nil {
+				return 0, err
+			}
+		}
+		if exponent >>= 1; exponent == 0 {
+			return result, nil
+		}
+		if base, err = mul(base, base); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// <- Since wrapping multiplication is still associative, squaring wraps
+//	  around to the very same result that the long way round would.
+
+// And while we're at it, let's also be able to compare things.
+// A comparison is also a binary operation, but its result is
+// a boolean, and since every element gives us an int, we'll say
+// that true is 1 and false is 0.
+
+type Comparison int
+
+const (
+	GreaterThan Comparison = iota
+	LessThan
+	EqualTo
+)
+
+type ComparisonOperation struct {
+	Type        Comparison
+	Left, Right Element
+}
+
+func (c *ComparisonOperation) Value() int {
+	var result bool
+	switch c.Type {
+	case GreaterThan:
+		result = c.Left.Value() > c.Right.Value()
+	case LessThan:
+		result = c.Left.Value() < c.Right.Value()
+	case EqualTo:
+		result = c.Left.Value() == c.Right.Value()
+	default:
+		panic("Unsupported comparison")
+	}
+	if result {
+		return 1
+	}
+	return 0
+}
+
+// And finally, a handful of built-in functions, like max(1, 2).
+// A function call is an element like any other, it just has
+// a name and any number of elements as its arguments.
+
+type builtin struct {
+	arity int
+	apply func(args []int) int
+}
+
+var builtins = map[string]builtin{
+	"max": {2, func(args []int) int { return max(args[0], args[1]) }},
+	"min": {2, func(args []int) int { return min(args[0], args[1]) }},
+	"abs": {1, func(args []int) int {
+		if args[0] < 0 {
+			return -args[0]
+		}
+		return args[0]
+	}},
+}
+
+type FunctionCall struct {
+	Name string
+	Args []Element
+}
+
+func (f *FunctionCall) Value() int {
+	args := make([]int, len(f.Args))
+	for i, arg := range f.Args {
+		args[i] = arg.Value()
+	}
+	return builtins[f.Name].apply(args)
+}
+
+// <- Whether the function exists, and whether it got the right
+//	  number of arguments, is checked by the parser, so by the time
+//	  we get to evaluate a call, we know it's a good one.
+
+// Wrapping around is fine for Go, where it's documented, but
+// whoever types 2000000000+2000000000 into our calculator, on a machine
+// with 32-bit ints, expects a big number, not a negative one.
+// So we can also evaluate a tree while checking every step.
+
+var ErrOverflow = errors.New("integer overflow")
+
+func checkedAdd(a, b int) (int, error) {
+	if (b > 0 && a > math.MaxInt-b) || (b < 0 && a < math.MinInt-b) {
+		return 0, fmt.Errorf("%d+%d: %w", a, b, ErrOverflow)
+	}
+	return a + b, nil
+}
+
+func checkedMul(a, b int) (int, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	p := a * b
+	if p/b != a || (a == -1 && b == math.MinInt) || (b == -1 && a == math.MinInt) {
+		return 0, fmt.Errorf("%d*%d: %w", a, b, ErrOverflow)
+	}
+	return p, nil
+}
+
+func (b *BinaryOperation) checked(l, r int) (int, error) {
+	switch b.Type {
+	case Addition:
+		return checkedAdd(l, r)
+	case Substraction:
+		if r == math.MinInt {
+			return 0, fmt.Errorf("%d-%d: %w", l, r, ErrOverflow)
+		}
+		return checkedAdd(l, -r)
+	case Multiplication:
+		return checkedMul(l, r)
+	case Division:
+		if r == 0 {
+			return 0, errors.New("division by zero")
+		}
+		if l == math.MinInt && r == -1 {
+			return 0, fmt.Errorf("%d/%d: %w", l, r, ErrOverflow)
+		}
+		return l / r, nil
+	case Power:
+		result, err := powerWith(l, r, checkedMul)
+		if err != nil {
+			return 0, fmt.Errorf("%d^%d: %w", l, r, ErrOverflow)
+		}
+		return result, nil
+	}
+	return 0, fmt.Errorf("unsupported operation %d", b.Type)
+}
+
+// The checked evaluation walks the tree just like Value() does,
+// only every step can fail, and the first failure stops everything.
+
+func EvaluateChecked(e Element) (int, error) {
+	switch e := e.(type) {
+	case *BinaryOperation:
+		l, err := EvaluateChecked(e.Left)
+		if err != nil {
+			return 0, err
+		}
+		r, err := EvaluateChecked(e.Right)
+		if err != nil {
+			return 0, err
+		}
+		return e.checked(l, r)
+	case *ComparisonOperation:
+		l, err := EvaluateChecked(e.Left)
+		if err != nil {
+			return 0, err
+		}
+		r, err := EvaluateChecked(e.Right)
+		if err != nil {
+			return 0, err
+		}
+		return (&ComparisonOperation{e.Type, NewInteger(l), NewInteger(r)}).Value(), nil
+	case *FunctionCall:
+		args := make([]Element, len(e.Args))
+		for i, arg := range e.Args {
+			v, err := EvaluateChecked(arg)
+			if err != nil {
+				return 0, err
+			}
+			args[i] = NewInteger(v)
+		}
+		if e.Name == "abs" {
+			if v := args[0].Value(); v == math.MinInt {
+				return 0, fmt.Errorf("abs(%d): %w", v, ErrOverflow)
+			}
+		}
+		return (&FunctionCall{e.Name, args}).Value(), nil
+	}
+	return e.Value(), nil
+}
+
+// <- Comparisons and functions can't overflow by themselves
+//	  (except for abs of the smallest int), so once their arguments
+//	  are known to be fine, the good old Value() does the rest.
+
 // With this whole setup, we need to write
 // a new function called Parse, which will turn this set
-// of tokens into a top level binary operation.
-
-// And we're going to assume that every expression is
-// a binary operation, as we tuned ourselves for that already.
-
-// The most complicated part of all
-// here is the left and right parentheses ( )
-// When we encounter the left parenteses -> ( <- we're going to
-// find a location where we encounter the right parenteses -> ) <-
-// and then we're going to take everything in between and we're going
-// to feed it recursively into the Parse method.
-// Thereby, we're getting an element and that element
-// is going to be stored.
-// Phew!
-
-func Parse(tokens []Token) Element {
-	res := BinaryOperation{}
-	haveLHS := false
-	for i := 0; i < len(tokens); i++ {
-		token := &tokens[i]
+// of tokens into a top level element.
+
+// Now that we have comparisons, we can't just assume that
+// every expression is a single binary operation anymore.
+// Something like 1+1==2 has to be read as (1+1)==2, which means
+// that comparisons have a lower precedence than + and -, that is,
+// they're applied last.
+
+// The simplest way to get this right is to have one
+// function per level of precedence, where every level
+// asks the level above it for its operands:
+// -> comparison: additive [> < ==] additive
+// -> additive: multiplicative [+ -] multiplicative [+ -] ...
+// -> multiplicative: power [* /] power [* /] ...
+// -> power: primary [^ power]
+// -> primary: a number, a whole expression in parentheses ( ),
+//	  or a function call, name(comparison, comparison, ...)
+
+// Notice that power is the odd one out. Everything else is
+// left-associative, 8-4-2 means (8-4)-2, but 2^3^2 means 2^(3^2),
+// so instead of looping, power calls itself for the right side.
+
+// When we encounter the left parenteses -> ( <- we just
+// start all over again from the lowest precedence, and then
+// we expect the right parenteses -> ) <- to close it.
+
+// And since the input is now typed in by people, we can't
+// just assume that everything is going to be well formed, so
+// every level reports back an error if something is off.
+
+type parser struct {
+	tokens []Token
+	pos    int
+}
+
+func Parse(tokens []Token) (Element, error) {
+	p := parser{tokens: tokens}
+	element, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token != nil {
+		return nil, fmt.Errorf("unexpected token %s at column %d", token, token.Pos)
+	}
+	return element, nil
+}
+
+func (p *parser) peek() *Token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) comparison() (Element, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token != nil {
+		res := ComparisonOperation{Left: left}
+		switch token.Type {
+		case Greater:
+			res.Type = GreaterThan
+		case Less:
+			res.Type = LessThan
+		case Equal:
+			res.Type = EqualTo
+		default:
+			return left, nil
+		}
+		p.pos++
+		if res.Right, err = p.additive(); err != nil {
+			return nil, err
+		}
+		return &res, nil
+	}
+	return left, nil
+}
+
+func (p *parser) additive() (Element, error) {
+	left, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for token := p.peek(); token != nil; token = p.peek() {
+		res := BinaryOperation{Left: left}
 		switch token.Type {
-		case Int:
-			// we're assuming this will always succeed
-			n, _ := strconv.Atoi(token.Text)
-			integer := Integer{n}
-			if !haveLHS {
-				res.Left = &integer
-				haveLHS = true
-			} else {
-				res.Right = &integer
-			}
 		case Plus:
 			res.Type = Addition
 		case Minus:
 			res.Type = Substraction
-		// ( )
-		case Lparen:
-			j := i // yeah, this is outside, we need it later
-			for ; j < len(tokens); j++ {
-				if tokens[j].Type == Rparen {
-					break
-				}
-			}
+		default:
+			return left, nil
+		}
+		p.pos++
+		if res.Right, err = p.multiplicative(); err != nil {
+			return nil, err
+		}
+		left = &res
+	}
+	return left, nil
+}
 
-			var subexp []Token
-			for k := i + 1; k < j; k++ {
-				subexp = append(subexp, tokens[k])
-			}
+func (p *parser) multiplicative() (Element, error) {
+	left, err := p.power()
+	if err != nil {
+		return nil, err
+	}
+	for token := p.peek(); token != nil; token = p.peek() {
+		res := BinaryOperation{Left: left}
+		switch token.Type {
+		case Times:
+			res.Type = Multiplication
+		case Divide:
+			res.Type = Division
+		default:
+			return left, nil
+		}
+		p.pos++
+		if res.Right, err = p.power(); err != nil {
+			return nil, err
+		}
+		left = &res
+	}
+	return left, nil
+}
 
-			element := Parse(subexp)
-			// we have the same thing as before, we need to decide
-			// wheteher or not this have the left hand side or right
-			if !haveLHS {
-				res.Left = element
-				haveLHS = true
-			} else {
-				res.Right = element
-			}
-			i = j
+func (p *parser) power() (Element, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if token := p.peek(); token == nil || token.Type != Caret {
+		return left, nil
+	}
+	p.pos++
+	right, err := p.power() // <- right associativity happens here
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryOperation{Power, left, right}, nil
+}
+
+func (p *parser) primary() (Element, error) {
+	token := p.peek()
+	if token == nil {
+		return nil, fmt.Errorf("unexpected end of input")
+	}
+	p.pos++
+	switch token.Type {
+	case Int:
+		n, err := strconv.Atoi(token.Text)
+		if err != nil {
+			return nil, err
+		}
+		return NewInteger(n), nil
+	case Lparen:
+		element, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		closing := p.peek()
+		if closing == nil {
+			return nil, fmt.Errorf("expected `)`")
+		}
+		if closing.Type != Rparen {
+			return nil, fmt.Errorf("expected `)` at column %d", closing.Pos)
+		}
+		p.pos++
+		return element, nil
+	case Ident:
+		return p.call(token)
+	default:
+		return nil, fmt.Errorf("unexpected token %s at column %d", token, token.Pos)
+	}
+}
+
+// A call is the name we've already consumed, then the arguments
+// in parentheses, separated by commas. Every argument starts over
+// from the lowest precedence, just like parentheses do.
+
+func (p *parser) call(name *Token) (Element, error) {
+	fn, ok := builtins[name.Text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s at column %d", name, name.Pos)
+	}
+	if token := p.peek(); token == nil || token.Type != Lparen {
+		return nil, fmt.Errorf("expected `(` after %s at column %d", name, name.Pos)
+	}
+	p.pos++
+
+	call := FunctionCall{Name: name.Text}
+	for {
+		arg, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+
+		token := p.peek()
+		if token == nil {
+			return nil, fmt.Errorf("expected `)`")
+		}
+		p.pos++
+		if token.Type == Rparen {
+			break
+		}
+		if token.Type != Comma {
+			return nil, fmt.Errorf("expected `,` or `)` at column %d", token.Pos)
 		}
 	}
 
-	return &res
+	if len(call.Args) != fn.arity {
+		return nil, fmt.Errorf("%s takes %d argument(s), got %d at column %d",
+			name, fn.arity, len(call.Args), name.Pos)
+	}
+	return &call, nil
 }
 
 type TokenType int
@@ -153,50 +546,237 @@ const (
 	Minus
 	Lparen
 	Rparen
+	Greater
+	Less
+	Equal
+	Times
+	Divide
+	Caret
+	Ident
+	Comma
 )
 
 type Token struct {
 	Type TokenType
 	Text string
+	Pos  int
 }
 
+// <- Pos is the column where the token starts, counting from 1,
+//	  so that when something goes wrong, we can point at it.
+
 func (t *Token) String() string {
 	return fmt.Sprintf("`%s`", t.Text)
 }
 
-func Lex(input string) []Token {
+func Lex(input string) ([]Token, error) {
 	var res []Token
 
 	for i := 0; i < len(input); i++ {
 		switch input[i] {
 		case '+':
-			res = append(res, Token{Plus, "+"})
+			res = append(res, Token{Plus, "+", i + 1})
 		case '-':
-			res = append(res, Token{Minus, "-"})
+			res = append(res, Token{Minus, "-", i + 1})
 		case '(':
-			res = append(res, Token{Lparen, "("})
+			res = append(res, Token{Lparen, "(", i + 1})
 		case ')':
-			res = append(res, Token{Rparen, ")"})
+			res = append(res, Token{Rparen, ")", i + 1})
+		case '*':
+			res = append(res, Token{Times, "*", i + 1})
+		case '/':
+			res = append(res, Token{Divide, "/", i + 1})
+		case '^':
+			res = append(res, Token{Caret, "^", i + 1})
+		case ',':
+			res = append(res, Token{Comma, ",", i + 1})
+		case '>':
+			res = append(res, Token{Greater, ">", i + 1})
+		case '<':
+			res = append(res, Token{Less, "<", i + 1})
+		case '=':
+			// == is the only operator with two characters
+			if i+1 >= len(input) || input[i+1] != '=' {
+				return nil, fmt.Errorf("unexpected '=' at column %d", i+1)
+			}
+			res = append(res, Token{Equal, "==", i + 1})
+			i++
+		case ' ', '\t':
+			// whitespace doesn't mean anything to us
 		default:
-			sb := strings.Builder{}
-			for j := i; j < len(input); j++ {
-				if unicode.IsDigit(rune(input[j])) {
-					sb.WriteRune(rune(input[j]))
-					i++
-				} else {
-					res = append(res, Token{Int, sb.String()})
-					i--
-					break
+			if unicode.IsLetter(rune(input[i])) {
+				start := i
+				for ; i < len(input) && unicode.IsLetter(rune(input[i])); i++ {
 				}
+				res = append(res, Token{Ident, input[start:i], start + 1})
+				i--
+				continue
+			}
+			if !unicode.IsDigit(rune(input[i])) {
+				return nil, fmt.Errorf("unexpected '%c' at column %d", input[i], i+1)
 			}
+			start := i
+			sb := strings.Builder{}
+			for ; i < len(input) && unicode.IsDigit(rune(input[i])); i++ {
+				sb.WriteRune(rune(input[i]))
+			}
+			res = append(res, Token{Int, sb.String(), start + 1})
+			i--
 		}
 	}
 
-	return res
+	return res, nil
 }
 
+// Before parsing, we can also do a little bit of optimization.
+// Whenever there's a number, an operator, and another number, and
+// nothing around them binds tighter, we can just do the math right
+// away and replace the three tokens with a single number.
+// And a lone number in parentheses is just that number.
+
+// The tricky bit is "nothing binds tighter". In 1+2*3 we can't
+// touch 1+2, and in 8-4-2 we can't touch 4-2, because the left side
+// goes first. With ^ it's the other way around, 2^3^2 is 2^(3^2).
+
+func precedence(t TokenType) (prec int, rightAssoc bool, isOperator bool) {
+	switch t {
+	case Greater, Less, Equal:
+		return 0, false, true
+	case Plus, Minus:
+		return 1, false, true
+	case Times, Divide:
+		return 2, false, true
+	case Caret:
+		return 3, true, true
+	}
+	return 0, false, false
+}
+
+// <- Comparisons are never folded, only one of them is allowed
+//	  per expression, and folding could hide that mistake.
+
+var foldable = map[TokenType]Operation{
+	Plus:   Addition,
+	Minus:  Substraction,
+	Times:  Multiplication,
+	Divide: Division,
+	Caret:  Power,
+}
+
+func foldAt(tokens []Token, i int) (Token, int, bool) {
+	if i+2 >= len(tokens) {
+		return Token{}, 0, false
+	}
+	left, op, right := tokens[i], tokens[i+1], tokens[i+2]
+
+	if left.Type == Lparen && op.Type == Int && right.Type == Rparen {
+		if i > 0 && tokens[i-1].Type == Ident {
+			return Token{}, 0, false // <- abs(3) is a call, not a (3)
+		}
+		return Token{Int, op.Text, left.Pos}, 3, true
+	}
+
+	operation, ok := foldable[op.Type]
+	if left.Type != Int || right.Type != Int || !ok {
+		return Token{}, 0, false
+	}
+	prec, rightAssoc, _ := precedence(op.Type)
+
+	if i > 0 && tokens[i-1].Type != Lparen && tokens[i-1].Type != Comma {
+		p, _, isOperator := precedence(tokens[i-1].Type)
+		if !isOperator || p > prec || (p == prec && !rightAssoc) {
+			return Token{}, 0, false
+		}
+	}
+	if i+3 < len(tokens) && tokens[i+3].Type != Rparen && tokens[i+3].Type != Comma {
+		p, _, isOperator := precedence(tokens[i+3].Type)
+		if !isOperator || p > prec || (p == prec && rightAssoc) {
+			return Token{}, 0, false
+		}
+	}
+
+	l, _ := strconv.Atoi(left.Text)
+	r, _ := strconv.Atoi(right.Text)
+	value, err := (&BinaryOperation{Type: operation}).checked(l, r)
+	if err != nil {
+		return Token{}, 0, false
+	}
+	return Token{Int, strconv.Itoa(value), left.Pos}, 3, true
+}
+
+// <- A comma ends an argument just like a closing parenthesis,
+//	  so max(1+1, 3) becomes max(2, 3).
+
+// Division by zero, or anything that overflows, is left for the evaluation to deal with,
+//	  and anything that wouldn't parse in the first place is left
+//	  alone as well, so the errors stay the same.
+
+// We keep folding until there's nothing left to fold, since
+// every fold might make another one possible.
+
+func Optimize(tokens []Token) []Token {
+	result := append([]Token(nil), tokens...)
+	for changed := true; changed; {
+		changed = false
+		for i := range result {
+			if folded, n, ok := foldAt(result, i); ok {
+				result = append(append(result[:i:i], folded), result[i+n:]...)
+				changed = true
+				break
+			}
+		}
+	}
+	return result
+}
+
+// <- Only numbers get folded, so once we have variables,
+//	  something like 2+x will simply be left as it is.
+
 // We can finally do our parsing! *ta-da*
 
+// And to make the whole thing interactive, we can put it in a loop
+// which reads an expression per line, and writes back either the
+// value or whatever went wrong, until there's nothing left to read.
+
+func Evaluate(input string) (int, error) {
+	tokens, err := Lex(input)
+	if err != nil {
+		return 0, err
+	}
+	element, err := Parse(tokens)
+	if err != nil {
+		return 0, err
+	}
+	return EvaluateChecked(element)
+}
+
+// <- People typing into a calculator would rather see an error
+//	  than a number that wrapped around, so we check.
+
+func RunREPL(in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+		if value, err := Evaluate(line); err != nil {
+			fmt.Fprintln(out, "error:", err)
+		} else {
+			fmt.Fprintln(out, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(out, "error:", err)
+	}
+}
+
+// <- Since it works with any reader and writer, it doesn't
+//	  care whether it's talking to a terminal, a file, or a string.
+
+// <- The scanner stops on its own when reading fails, or when a line
+//	  is too long for it, and we say so instead of just going quiet.
+
 // Recap:
 // -> This demonstration showed how to basically implement the Interpreter pattern
 // -> The idea is that typically we split it into two parts: Lexer and Parser
@@ -207,9 +787,23 @@ func Lex(input string) []Token {
 
 func main() {
 	input := "(13+4)-(12+1)"
-	tokens := Lex(input)
+	tokens, _ := Lex(input)
 	fmt.Println(tokens)
 
-	parsed := Parse(tokens)
+	parsed, _ := Parse(tokens)
 	fmt.Printf("%s = %d\n", input, parsed.Value())
+
+	for _, input := range []string{"2+3", "1+2*3", "8-4-2", "(2+3)*4", "2^3^2", "1/0", "max(1+1, 3)", "abs(7)"} {
+		tokens, _ := Lex(input)
+		var texts []string
+		for _, t := range Optimize(tokens) {
+			texts = append(texts, t.Text)
+		}
+		fmt.Println(input, "optimizes to", texts)
+	}
+
+	RunREPL(strings.NewReader("3>2\n2>3\n1+1==2\n(1+\n1+@\n(1 2)\n2^3\n2^3^2\n2*3^2\n"), os.Stdout)
+	RunREPL(strings.NewReader("max(1+1, 3)\nmin(4, 2*3)\nabs(2-5)\nsqrt(4)\nmax(1)\n"), os.Stdout)
+	RunREPL(strings.NewReader("2000000000+2000000000\n9000000000000000000+9000000000000000000\n3037000500*3037000500\n2^63\n1/0\n1^9000000000000000000\n"), os.Stdout)
+	// RunREPL(os.Stdin, os.Stdout)
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := map[string]int{
+		"(13+4)-(12+1)":            4,
+		"3>2":                      1,
+		"2>3":                      0,
+		"1+1==2":                   1,
+		"2^3":                      8,
+		"2^3^2":                    512,
+		"2*3^2":                    18,
+		"(2^3)^2":                  64,
+		"2^(0-1)":                  0,
+		"(0-2)^3":                  -8,
+		"1^9000000000000000000":    1,
+		"2^62":                     1 << 62,
+		"max(1+1, 3)":              3,
+		"min(4, 2*3)":              4,
+		"abs(2-5)":                 3,
+		"max(abs(0-7), min(2, 9))": 7,
+	}
+	for input, want := range tests {
+		got, err := Evaluate(input)
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+		} else if got != want {
+			t.Errorf("%s = %d, want %d", input, got, want)
+		}
+	}
+}
+
+func TestPowerBySquaring(t *testing.T) {
+	for base := -3; base <= 3; base++ {
+		want := 1
+		for exponent := 0; exponent <= 20; exponent++ {
+			if got := power(base, exponent); got != want {
+				t.Fatalf("%d^%d = %d, want %d", base, exponent, got, want)
+			}
+			want *= base
+		}
+	}
+
+	// wrapping the same way repeated multiplication does
+	want := 1
+	for i := 0; i < 100; i++ {
+		want *= 3
+	}
+	if got := power(3, 100); got != want {
+		t.Fatalf("3^100 = %d, want %d", got, want)
+	}
+}
+
+func TestErrorsReportColumns(t *testing.T) {
+	tests := map[string]string{
+		"1+@":     "unexpected '@' at column 3",
+		"(1 2)":   "expected `)` at column 4",
+		"1=2":     "unexpected '=' at column 2",
+		"(1+":     "unexpected end of input",
+		"sqrt(4)": "unknown function `sqrt` at column 1",
+		"max(1)":  "`max` takes 2 argument(s), got 1 at column 1",
+	}
+	for input, want := range tests {
+		if _, err := Evaluate(input); err == nil || err.Error() != want {
+			t.Errorf("%s: got %v, want %q", input, err, want)
+		}
+	}
+}
+
+func TestOverflowIsDetected(t *testing.T) {
+	for _, input := range []string{
+		"9000000000000000000+9000000000000000000",
+		"0-9000000000000000000-9000000000000000000",
+		"3037000500*3037000500",
+		"2^63",
+		"3^1000000000000",
+		"abs(0-9223372036854775807-1)",
+	} {
+		if _, err := Evaluate(input); !errors.Is(err, ErrOverflow) {
+			t.Errorf("%s: got %v, want an overflow", input, err)
+		}
+	}
+	if _, err := Evaluate("1/0"); err == nil || errors.Is(err, ErrOverflow) {
+		t.Errorf("1/0: got %v", err)
+	}
+	if v, err := checkedMul(-1, math.MaxInt); err != nil || v != -math.MaxInt {
+		t.Errorf("-1*MaxInt: got %d, %v", v, err)
+	}
+}
+
+func texts(tokens []Token) string {
+	var result []string
+	for _, t := range tokens {
+		result = append(result, t.Text)
+	}
+	return strings.Join(result, " ")
+}
+
+func TestOptimize(t *testing.T) {
+	tests := map[string]string{
+		"2+3":         "5",
+		"1+2*3":       "7",
+		"8-4-2":       "2",
+		"(2+3)*4":     "20",
+		"2^3^2":       "512",
+		"1/0":         "1 / 0",
+		"2^63":        "2 ^ 63",
+		"max(1+1, 3)": "max ( 2 , 3 )",
+		"abs(7)":      "abs ( 7 )",
+
+		// variables are never folded, and neither is anything
+		// that would change what they're combined with
+		"2+x":   "2 + x",
+		"x-1-2": "x - 1 - 2",
+		"2*3+x": "6 + x",
+		"x*2*3": "x * 2 * 3",
+	}
+	for input, want := range tests {
+		tokens, err := Lex(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := texts(Optimize(tokens)); got != want {
+			t.Errorf("%s optimizes to %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestRunREPL(t *testing.T) {
+	out := &strings.Builder{}
+	RunREPL(strings.NewReader("1+1\n\n  \n2>3\n1+@\n"), out)
+	want := "2\n0\nerror: unexpected '@' at column 3\n"
+	if out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunREPLReportsReadErrors(t *testing.T) {
+	out := &strings.Builder{}
+	broken := io.MultiReader(strings.NewReader("1+1\n"), iotest.ErrReader(errors.New("disk on fire")))
+	RunREPL(broken, out)
+	if want := "2\nerror: disk on fire\n"; out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	RunREPL(strings.NewReader(strings.Repeat("1+", bufio.MaxScanTokenSize)+"1\n"), out)
+	if want := "error: " + bufio.ErrTooLong.Error() + "\n"; out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
@@ -28,7 +28,10 @@
 
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
 // Let's start, with a type.
 
@@ -133,12 +136,20 @@ func (i *InOrderIterator) MoveNext() bool {
 	return i.Current != nil
 }
 
+// And to be consistent with the iterator from the previous
+// lesson, let's also expose the current value through a method.
+
+func (i *InOrderIterator) Value() int {
+	return i.Current.Value
+}
+
 // And this works, but let's suppose that we want to have
 // a really nicely packaged implementation of both in-order travers
 // as well as other forms of traversal.
 // We can go ahead and create a new struct.
 
 type BinaryTree struct {
+	mu   sync.RWMutex
 	root *Node
 }
 
@@ -150,6 +161,211 @@ func (b *BinaryTree) InOrder() *InOrderIterator {
 	return NewInOrderIterator(b.root)
 }
 
+// Trees usually don't stay the same forever, so let's be able
+// to add values to ours. Smaller values go to the left and
+// everything else to the right, like in a binary search tree.
+
+func (b *BinaryTree) Insert(value int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := NewTerminalNode(value)
+	if b.root == nil {
+		b.root = n
+		return
+	}
+	for current := b.root; ; {
+		next := &current.right
+		if value < current.Value {
+			next = &current.left
+		}
+		if *next == nil {
+			*next = n
+			n.parent = current
+			return
+		}
+		current = *next
+	}
+}
+
+// But now there's a problem. The in-order iterator walks
+// the tree by following pointers as it goes, so if somebody
+// inserts something half way through, the iterator might or
+// might not see it, depending on where it currently is.
+
+// If we want to be sure what we're going to get, we can
+// take a snapshot of the nodes up front and iterate over that.
+
+type SnapshotIterator struct {
+	nodes   []*Node
+	current int
+}
+
+func (b *BinaryTree) Snapshot() *SnapshotIterator {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	s := &SnapshotIterator{current: -1}
+	if b.root == nil {
+		return s
+	}
+	for it := b.InOrder(); it.MoveNext(); {
+		s.nodes = append(s.nodes, it.Current)
+	}
+	return s
+}
+
+// <- The lock makes sure nobody inserts anything while
+//	  we're taking the snapshot, after that, we don't care.
+
+func (s *SnapshotIterator) MoveNext() bool {
+	s.current++
+	return s.current < len(s.nodes)
+}
+
+func (s *SnapshotIterator) Value() int {
+	return s.nodes[s.current].Value
+}
+
+// <- The price is that we have to walk the whole tree,
+//	  and hold on to all of its nodes, before we even start.
+
+// Bonus:
+// Now that both the person names and the tree have
+// iterators with MoveNext and Value, we can describe
+// any such iterator generically.
+
+type Iterator[T any] interface {
+	MoveNext() bool
+	Value() T
+}
+
+// The simplest possible one goes over a slice.
+
+type SliceIterator[T any] struct {
+	items   []T
+	current int
+}
+
+func NewSliceIterator[T any](items []T) *SliceIterator[T] {
+	return &SliceIterator[T]{items: items, current: -1}
+}
+
+func (s *SliceIterator[T]) MoveNext() bool {
+	s.current++
+	return s.current < len(s.items)
+}
+
+func (s *SliceIterator[T]) Value() T {
+	return s.items[s.current]
+}
+
+// And with that we can build wrappers which work for any of them.
+// For example, quite often, say when parsing, we need to take
+// a look at the next value without actually consuming it.
+
+type Peekable[T any] struct {
+	it            Iterator[T]
+	current, next T
+	peeked, ok    bool
+}
+
+func NewPeekable[T any](it Iterator[T]) *Peekable[T] {
+	return &Peekable[T]{it: it}
+}
+
+// ↑↑↑ We have to keep our own copy of the current value,
+//	   because peeking moves the underlying iterator ahead.
+
+func (p *Peekable[T]) Peek() (T, bool) {
+	if !p.peeked {
+		p.peeked = true
+		p.ok = p.it.MoveNext()
+		if p.ok {
+			p.next = p.it.Value()
+		} else {
+			var zero T
+			p.next = zero
+		}
+	}
+	return p.next, p.ok
+}
+
+func (p *Peekable[T]) MoveNext() bool {
+	if !p.peeked {
+		p.Peek()
+	}
+	p.peeked = false
+	if p.ok {
+		p.current = p.next
+	}
+	return p.ok
+}
+
+func (p *Peekable[T]) Value() T {
+	return p.current
+}
+
+// Another common wrapper only lets through the values
+// we're actually interested in, like only the even numbers.
+
+type FilterIterator[T any] struct {
+	it        Iterator[T]
+	predicate func(T) bool
+}
+
+func NewFilterIterator[T any](it Iterator[T], predicate func(T) bool) *FilterIterator[T] {
+	return &FilterIterator[T]{it, predicate}
+}
+
+// Moving next simply keeps moving the underlying
+// iterator until it lands on something that matches.
+
+func (f *FilterIterator[T]) MoveNext() bool {
+	for f.it.MoveNext() {
+		if f.predicate(f.it.Value()) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FilterIterator[T]) Value() T {
+	return f.it.Value()
+}
+
+// And sometimes we want to walk two sequences side by side,
+// like the values of a tree together with their positions.
+// Zipping gives us both of them at once, as a pair.
+
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+
+type ZipIterator[A, B any] struct {
+	a Iterator[A]
+	b Iterator[B]
+}
+
+func Zip[A, B any](a Iterator[A], b Iterator[B]) *ZipIterator[A, B] {
+	return &ZipIterator[A, B]{a, b}
+}
+
+// Both have to move for us to have a pair, so as soon as
+// either of them runs out, so do we.
+
+func (z *ZipIterator[A, B]) MoveNext() bool {
+	return z.a.MoveNext() && z.b.MoveNext()
+}
+
+func (z *ZipIterator[A, B]) Value() Pair[A, B] {
+	return Pair[A, B]{z.a.Value(), z.b.Value()}
+}
+
+// <- If the first one runs out, we never even touch the second,
+//	  so the second one may have been moved one time fewer.
+
 // Recap:
 // ->	This has hopefully ilustrated why we would want
 //		to construct different iterator objects
@@ -177,4 +393,30 @@ func main() {
 		fmt.Printf("%d,", i.Current.Value)
 	}
 	fmt.Println("\b")
+
+	p := NewPeekable[int](t.InOrder())
+	for p.MoveNext() {
+		next, ok := p.Peek()
+		fmt.Println(p.Value(), "next:", next, ok)
+	}
+
+	even := func(n int) bool { return n%2 == 0 }
+	for f := NewFilterIterator[int](NewSliceIterator([]int{1, 2, 3, 4, 5, 6}), even); f.MoveNext(); {
+		fmt.Printf("%d,", f.Value())
+	}
+	fmt.Println("\b")
+
+	snapshot := t.Snapshot()
+	t.Insert(4)
+	for snapshot.MoveNext() {
+		fmt.Printf("%d,", snapshot.Value())
+	}
+	fmt.Println("\b <- no 4 in the snapshot")
+
+	positions := NewSliceIterator([]string{"first", "second", "third", "fourth", "fifth"})
+	for z := Zip[int, string](t.InOrder(), positions); z.MoveNext(); {
+		fmt.Printf("%s: %d\n", z.Value().Second, z.Value().First)
+	}
+	empty := Zip[int, string](NewSliceIterator([]int{}), NewSliceIterator([]string{"lonely"}))
+	fmt.Println("Anything zipped with nothing:", empty.MoveNext())
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func collect[T any](it Iterator[T]) []T {
+	var result []T
+	for it.MoveNext() {
+		result = append(result, it.Value())
+	}
+	return result
+}
+
+func TestPeekable(t *testing.T) {
+	p := NewPeekable[int](NewSliceIterator([]int{1, 2, 3}))
+	if v, ok := p.Peek(); !ok || v != 1 {
+		t.Fatalf("first peek: %d, %v", v, ok)
+	}
+	if v, _ := p.Peek(); v != 1 {
+		t.Fatal("peeking twice moved the iterator")
+	}
+
+	var values, peeked []int
+	for p.MoveNext() {
+		values = append(values, p.Value())
+		next, _ := p.Peek()
+		peeked = append(peeked, next)
+	}
+	if !reflect.DeepEqual(values, []int{1, 2, 3}) || !reflect.DeepEqual(peeked, []int{2, 3, 0}) {
+		t.Fatalf("values %v, peeked %v", values, peeked)
+	}
+	if _, ok := p.Peek(); ok {
+		t.Fatal("peeked past the end")
+	}
+}
+
+func TestFilterIterator(t *testing.T) {
+	even := func(n int) bool { return n%2 == 0 }
+	got := collect[int](NewFilterIterator[int](NewSliceIterator([]int{1, 2, 3, 4, 5, 6}), even))
+	if !reflect.DeepEqual(got, []int{2, 4, 6}) {
+		t.Fatalf("got %v", got)
+	}
+	none := NewFilterIterator[int](NewSliceIterator([]int{1, 3}), even)
+	if none.MoveNext() {
+		t.Fatal("nothing should get through")
+	}
+}
+
+func TestSnapshotIgnoresLaterInserts(t *testing.T) {
+	tree := NewBinaryTree(nil)
+	for _, v := range []int{5, 3, 8} {
+		tree.Insert(v)
+	}
+	snapshot := tree.Snapshot()
+	tree.Insert(4)
+
+	if got := collect[int](snapshot); !reflect.DeepEqual(got, []int{3, 5, 8}) {
+		t.Fatalf("snapshot %v", got)
+	}
+	if got := collect[int](tree.Snapshot()); !reflect.DeepEqual(got, []int{3, 4, 5, 8}) {
+		t.Fatalf("after the insert %v", got)
+	}
+	if NewBinaryTree(nil).Snapshot().MoveNext() {
+		t.Fatal("an empty tree has values")
+	}
+}
+
+func TestSnapshotWhileInserting(t *testing.T) {
+	tree := NewBinaryTree(nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			tree.Insert(i)
+		}()
+		go func() {
+			defer wg.Done()
+			if values := collect[int](tree.Snapshot()); !sort.IntsAreSorted(values) {
+				t.Errorf("unsorted snapshot %v", values)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(collect[int](tree.Snapshot())); n != 50 {
+		t.Fatalf("%d values, want 50", n)
+	}
+}
+
+func TestZip(t *testing.T) {
+	tree := NewBinaryTree(NewNode(1, NewTerminalNode(2), NewTerminalNode(3)))
+	z := Zip[int, string](tree.InOrder(), NewSliceIterator([]string{"a", "b"}))
+	got := collect[Pair[int, string]](z)
+	want := []Pair[int, string]{{2, "a"}, {1, "b"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	empty := Zip[int, string](NewSliceIterator([]int{}), NewSliceIterator([]string{"lonely"}))
+	if empty.MoveNext() {
+		t.Fatal("zipped something with nothing")
+	}
+}
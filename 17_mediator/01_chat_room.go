@@ -7,7 +7,10 @@
 
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // So, we'll begin by defining a participant of the chat room.
 
@@ -62,6 +65,15 @@ func (p *Person) PrivateMessage(who, message string) {
 
 type ChatRoom struct {
 	people []*Person
+
+	// rate limiting, see below
+	MessageLimit int
+	Window       time.Duration
+	Clock        Clock
+	sent         map[string][]time.Time
+
+	// undeliverable messages, see below
+	DeadLetters DeadLetterHandler
 }
 
 // What we want to do with this is that we can first of all
@@ -69,23 +81,75 @@ type ChatRoom struct {
 // So we can specify a message as well as the source of
 // that message and we can send it to every participant in the Chatroom.
 
-func (c *ChatRoom) Broadcast(source, message string) {
+// And since everything goes through the room, the room is also
+// the one who knows who actually got the message, so it can
+// hand back a receipt, recipient name -> delivered.
+// Whoever doesn't care about it, can simply ignore it.
+
+func (c *ChatRoom) Broadcast(source, message string) map[string]bool {
+	if !c.allow(source) {
+		c.Message("Room", source, "Slow down! Your message was not sent.")
+		return map[string]bool{}
+	}
+	return c.deliver(source, message)
+}
+
+// The room has a few things to say on its own, like who
+// joined or left, and those are never rate limited.
+// They don't go through Broadcast at all, so nobody can
+// get around the limit by calling themselves "Room".
+
+func (c *ChatRoom) announce(message string) {
+	c.deliver("Room", message)
+}
+
+func (c *ChatRoom) deliver(source, message string) map[string]bool {
+	receipts := map[string]bool{}
 	for _, p := range c.people {
 		if p.Name != source {
 			p.Receive(source, message)
+			receipts[p.Name] = true
 		}
 	}
+	return receipts
 }
 
 // Also, we need to send a targeted message from
 // one participant to another.
 
 func (c *ChatRoom) Message(source, destination, message string) {
+	delivered := false
 	for _, p := range c.people {
 		if p.Name == destination {
 			p.Receive(source, message)
+			delivered = true
 		}
 	}
+	if !delivered && c.DeadLetters != nil {
+		c.DeadLetters.HandleDeadLetter(DeadLetter{source, destination, message})
+	}
+}
+
+// <- If nobody by that name is in the room, the message has
+//	  nowhere to go. Instead of letting it vanish, the room can hand
+//	  it over to somebody who deals with that, to log it or try again later.
+
+type DeadLetter struct {
+	Source, Destination, Message string
+}
+
+type DeadLetterHandler interface {
+	HandleDeadLetter(letter DeadLetter)
+}
+
+// The simplest handler just keeps them.
+
+type DeadLetterBox struct {
+	Letters []DeadLetter
+}
+
+func (d *DeadLetterBox) HandleDeadLetter(letter DeadLetter) {
+	d.Letters = append(d.Letters, letter)
 }
 
 // And the final thing we want to add is a method for
@@ -93,12 +157,108 @@ func (c *ChatRoom) Message(source, destination, message string) {
 
 func (c *ChatRoom) Join(p *Person) {
 	joinMsg := p.Name + " joins the chat"
-	c.Broadcast("Room", joinMsg)
+	c.announce(joinMsg)
 
 	p.Room = c
 	c.people = append(c.people, p)
 }
 
+// And, of course, people leave.
+
+func (c *ChatRoom) Leave(p *Person) {
+	for i, person := range c.people {
+		if person == p {
+			c.people = append(c.people[:i], c.people[i+1:]...)
+			p.Room = nil
+			c.announce(p.Name + " leaves the chat")
+			return
+		}
+	}
+}
+
+// Now, since every message goes through the room, the room
+// is also the perfect place to deal with spammers.
+// Nobody gets to say more than some number of messages
+// within some window of time, and if they try, the message
+// is dropped and the room tells them so.
+
+// To check this without waiting around, time comes from a clock.
+
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *ChatRoom) allow(source string) bool {
+	if c.MessageLimit <= 0 {
+		return true
+	}
+	if c.Clock == nil {
+		c.Clock = realClock{}
+	}
+	if c.sent == nil {
+		c.sent = map[string][]time.Time{}
+	}
+
+	now := c.Clock.Now()
+	var recent []time.Time
+	for _, t := range c.sent[source] {
+		if now.Sub(t) < c.Window {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= c.MessageLimit {
+		c.sent[source] = recent
+		return false
+	}
+	c.sent[source] = append(recent, now)
+	return true
+}
+
+// <- No limit means no limit.
+
+// A single room is a bit lonely. A real chat server hosts lots
+// of rooms, and they're completely independent of one another,
+// whatever is said in one of them stays there.
+
+// So there's another mediator on top, which doesn't deal with
+// messages at all, only with who is in which room.
+
+type ChatServer struct {
+	rooms map[string]*ChatRoom
+}
+
+func NewChatServer() *ChatServer {
+	return &ChatServer{map[string]*ChatRoom{}}
+}
+
+// Rooms are created the first time somebody joins them,
+// and since a person is only ever in one room at a time,
+// joining another room means leaving the current one.
+
+func (s *ChatServer) Join(room string, p *Person) {
+	if p.Room != nil {
+		p.Room.Leave(p)
+	}
+	r, ok := s.rooms[room]
+	if !ok {
+		r = &ChatRoom{}
+		s.rooms[room] = r
+	}
+	r.Join(p)
+}
+
+func (s *ChatServer) Room(name string) *ChatRoom {
+	return s.rooms[name]
+}
+
+// <- nil if nobody has ever joined it
+
 // Now we have everything ready, and we can try out
 // our little chatroom.
 
@@ -144,4 +304,25 @@ func main() {
 	fatAbbot.Say("Yeah, b***h! Snatch his ass in a bear trap! Leave that m***erfu***r swingin' from a tree so high nobody finds him for days! Glock-glock, you know what I'm sayin'? Dumbassed m***erfu***r pullin' s**t! Damn!")
 
 	yolanda.PrivateMessage("Fat Abbot", "You're right, Fat Abbot. Thanks!")
+
+	receipts := room.Broadcast(rudy.Name, "Yolanda, don't listen to him!")
+	fmt.Println("Delivered to:", receipts)
+
+	room.MessageLimit, room.Window = 3, time.Minute
+	for i := 0; i < 4; i++ {
+		rudy.Say("Fat Abbot!")
+	}
+
+	server := NewChatServer()
+	stan, kyle := NewPerson("Stan"), NewPerson("Kyle")
+	server.Join("bus stop", stan)
+	server.Join("school", kyle)
+	stan.Say("Anybody here?") // <- Kyle is in another room
+	server.Join("bus stop", kyle)
+	stan.Say("Dude!")
+
+	box := &DeadLetterBox{}
+	server.Room("bus stop").DeadLetters = box
+	stan.PrivateMessage("Cartman", "Where are you?") // <- not in this room
+	fmt.Printf("Undelivered: %+v\n", box.Letters)
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestBroadcastReceipts(t *testing.T) {
+	room := &ChatRoom{}
+	abbot, rudy, yolanda := NewPerson("Fat Abbot"), NewPerson("Rudy"), NewPerson("Yolanda")
+	room.Join(abbot)
+	room.Join(rudy)
+	room.Join(yolanda)
+
+	receipts := room.Broadcast(rudy.Name, "hi")
+	if len(receipts) != 2 || !receipts["Fat Abbot"] || !receipts["Yolanda"] {
+		t.Fatalf("got %v", receipts)
+	}
+	if last := yolanda.chatLog[len(yolanda.chatLog)-1]; last != "Rudy: hi\n" {
+		t.Fatalf("got %q", last)
+	}
+}
+
+func TestRateLimitPerParticipant(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	room := &ChatRoom{MessageLimit: 3, Window: time.Minute, Clock: clock}
+	rudy, stan := NewPerson("Rudy"), NewPerson("Stan")
+	room.Join(rudy)
+	room.Join(stan)
+
+	for i := 0; i < 3; i++ {
+		if len(room.Broadcast("Rudy", "Fat Abbot!")) != 1 {
+			t.Fatalf("message %d was not delivered", i+1)
+		}
+	}
+	if len(room.Broadcast("Rudy", "Fat Abbot!")) != 0 {
+		t.Fatal("the fourth message got through")
+	}
+	if last := rudy.chatLog[len(rudy.chatLog)-1]; last != "Room: Slow down! Your message was not sent.\n" {
+		t.Fatalf("Rudy was not told: %q", last)
+	}
+	if len(room.Broadcast("Stan", "Dude")) != 1 {
+		t.Fatal("Stan was limited by Rudy's messages")
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	if len(room.Broadcast("Rudy", "Fat Abbot!")) != 1 {
+		t.Fatal("still limited once the window is over")
+	}
+}
+
+func TestRoomNameIsNotExempt(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	room := &ChatRoom{MessageLimit: 3, Window: time.Minute, Clock: clock}
+	stan := NewPerson("Stan")
+	room.Join(stan)
+
+	for i := 0; i < 3; i++ {
+		room.Broadcast("Room", "Free pizza!")
+	}
+	if len(room.Broadcast("Room", "Free pizza!")) != 0 {
+		t.Fatal("calling yourself Room gets around the limit")
+	}
+
+	// The room's own announcements still get through.
+	room.Join(NewPerson("Kyle"))
+	if last := stan.chatLog[len(stan.chatLog)-1]; last != "Room: Kyle joins the chat\n" {
+		t.Fatalf("got %q", last)
+	}
+}
+
+func TestServerRooms(t *testing.T) {
+	server := NewChatServer()
+	stan, kyle := NewPerson("Stan"), NewPerson("Kyle")
+	server.Join("bus stop", stan)
+	server.Join("school", kyle)
+
+	if receipts := stan.Room.Broadcast("Stan", "Anybody here?"); len(receipts) != 0 {
+		t.Fatalf("delivered across rooms: %v", receipts)
+	}
+
+	server.Join("bus stop", kyle)
+	if kyle.Room != server.Room("bus stop") || len(server.Room("school").people) != 0 {
+		t.Fatal("Kyle did not move rooms")
+	}
+	if receipts := stan.Room.Broadcast("Stan", "Dude!"); !receipts["Kyle"] {
+		t.Fatalf("got %v", receipts)
+	}
+}
+
+func TestDeadLetters(t *testing.T) {
+	room := &ChatRoom{}
+	stan := NewPerson("Stan")
+	room.Join(stan)
+
+	stan.PrivateMessage("Cartman", "Where are you?") // <- no handler, simply dropped
+
+	box := &DeadLetterBox{}
+	room.DeadLetters = box
+	stan.PrivateMessage("Cartman", "Where are you?")
+	stan.PrivateMessage("Stan", "Note to self")
+
+	want := DeadLetter{"Stan", "Cartman", "Where are you?"}
+	if len(box.Letters) != 1 || box.Letters[0] != want {
+		t.Fatalf("got %+v", box.Letters)
+	}
+}
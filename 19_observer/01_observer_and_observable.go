@@ -21,7 +21,12 @@ package main
 
 import (
 	"container/list"
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // There's gonna be two participants to this story.
@@ -29,9 +34,14 @@ import (
 // is called the -> Observer.
 
 type Observable struct {
-	subs *list.List // list of Observers, that are connected to this
+	mu      sync.RWMutex
+	subs    *list.List // list of Observers, that are connected to this
+	metrics atomic.Pointer[fireMetrics]
 }
 
+// <- Events can come from any goroutine, and so can
+//	  the subscribers, so the list is guarded by a lock.
+
 type Observer interface {
 	Notify(data interface{})
 }
@@ -48,14 +58,18 @@ type Observer interface {
 // on the observable.
 
 func (o *Observable) Subscribe(x Observer) {
-	o.subs.PushBack(x)
+	o.SubscribeWithPriority(x, 0)
 }
 
+// <- Ignore the priority for now, we'll come back to it.
+
 // Similarly to this, we can have a way of unsubscribing.
 
 func (o *Observable) Unsubscribe(x Observer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 	for z := o.subs.Front(); z != nil; z = z.Next() {
-		if z.Value.(Observer) == x {
+		if s := z.Value.(subscription).observer; s == x || unwrapCtx(s) == x {
 			o.subs.Remove(z)
 		}
 	}
@@ -71,9 +85,198 @@ func (o *Observable) Unsubscribe(x Observer) {
 // The method that will notify the Observer that something happens.
 
 func (o *Observable) Fire(data interface{}) {
+	if m := o.metrics.Load(); m != nil {
+		defer m.record(time.Now())
+	}
+	for _, x := range o.snapshot() {
+		x.Notify(data)
+	}
+}
+
+// <- We don't hold the lock while notifying. Instead we take
+//	  a copy of the observers, so an observer is free to subscribe
+//	  or unsubscribe somebody in the middle of a notification
+//	  without deadlocking, and the change applies from the next event.
+
+func (o *Observable) snapshot() []Observer {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	observers := make([]Observer, 0, o.subs.Len())
 	for z := o.subs.Front(); z != nil; z = z.Next() {
-		z.Value.(Observer).Notify(data)
+		observers = append(observers, z.Value.(subscription).observer)
+	}
+	return observers
+}
+
+// Sometimes the order in which observers get notified matters,
+// because one observer's side effect has to happen before another's.
+// So an observer can ask to be notified earlier, by giving itself
+// a higher priority. Everybody else simply gets priority 0.
+
+type subscription struct {
+	observer Observer
+	priority int
+}
+
+// We keep the list sorted, highest priority first, so Fire()
+// doesn't need to know anything about priorities. A new observer
+// goes after everybody with the same priority, so among equals
+// whoever subscribed first gets notified first.
+
+func (o *Observable) SubscribeWithPriority(x Observer, priority int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s := subscription{x, priority}
+	for z := o.subs.Back(); z != nil; z = z.Prev() {
+		if z.Value.(subscription).priority >= priority {
+			o.subs.InsertAfter(s, z)
+			return
+		}
+	}
+	o.subs.PushFront(s)
+}
+
+// Quite often, an observer only cares for as long as some
+// piece of work is going on, say a request being served.
+// In Go, the lifetime of such work is a context, so we can tie
+// the subscription to it, and have it go away on its own.
+
+type ctxObserver struct {
+	ctx      context.Context
+	observer Observer
+}
+
+func (c *ctxObserver) Notify(data interface{}) {
+	if c.ctx.Err() == nil {
+		c.observer.Notify(data)
+	}
+}
+
+// <- The unsubscribing happens in the background, so right after
+//	  the cancellation there might still be an event or two on the way.
+//	  Checking the context here means the observer never sees them.
+
+func unwrapCtx(x Observer) Observer {
+	if c, ok := x.(*ctxObserver); ok {
+		return c.observer
+	}
+	return x
+}
+
+func (o *Observable) SubscribeCtx(ctx context.Context, x Observer) {
+	w := &ctxObserver{ctx, x}
+	o.Subscribe(w)
+	context.AfterFunc(ctx, func() {
+		o.Unsubscribe(w)
+	})
+}
+
+// <- The observer can still be unsubscribed by hand,
+//	  it doesn't have to wait for the context.
+
+// Since Fire() calls every observer one after another, a single
+// slow observer makes every event slow for everybody.
+// To find out whether that's happening, the observable can time
+// how long it takes to get an event to all of its observers.
+
+type fireMetrics struct {
+	last, total, count atomic.Int64
+}
+
+func (m *fireMetrics) record(start time.Time) {
+	d := int64(time.Since(start))
+	m.last.Store(d)
+	m.total.Add(d)
+	m.count.Add(1)
+}
+
+// <- Until somebody asks for them there are no metrics at all,
+//	  and all Fire() pays for that is a single nil check.
+
+func (o *Observable) EnableMetrics() {
+	o.metrics.CompareAndSwap(nil, &fireMetrics{})
+}
+
+func (o *Observable) LastFireDuration() time.Duration {
+	if m := o.metrics.Load(); m != nil {
+		return time.Duration(m.last.Load())
+	}
+	return 0
+}
+
+func (o *Observable) AverageFireDuration() time.Duration {
+	m := o.metrics.Load()
+	if m == nil || m.count.Load() == 0 {
+		return 0
+	}
+	return time.Duration(m.total.Load() / m.count.Load())
+}
+
+// Fire() does all of its work on the caller's goroutine, so whoever
+// fires has to wait for every observer. When there are lots of events,
+// we'd rather hand them off to a few workers and carry on.
+
+// But if events come in faster than the workers can handle them,
+// they pile up, so the queue they wait in has a limit. Once it's full,
+// whoever fires either waits for a free spot, or gets told to back off.
+
+type FullQueuePolicy int
+
+const (
+	Block FullQueuePolicy = iota
+	Reject
+)
+
+var ErrQueueFull = errors.New("observer: event queue is full")
+
+type WorkerPool struct {
+	target *Observable
+	queue  chan interface{}
+	policy FullQueuePolicy
+	wg     sync.WaitGroup
+}
+
+func NewWorkerPool(target *Observable, workers, queueSize int, policy FullQueuePolicy) *WorkerPool {
+	p := &WorkerPool{
+		target: target,
+		queue:  make(chan interface{}, queueSize),
+		policy: policy,
 	}
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for data := range p.queue {
+				p.target.Fire(data)
+			}
+		}()
+	}
+	return p
+}
+
+// <- Every event still goes to every observer, but with more than one
+//	  worker, two events can be delivered at the same time, and not
+//	  necessarily in the order in which they were fired.
+
+func (p *WorkerPool) Fire(data interface{}) error {
+	if p.policy == Reject {
+		select {
+		case p.queue <- data:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+	p.queue <- data
+	return nil
+}
+
+// Closing lets the workers finish whatever is still queued up,
+// and waits for them. Firing after that is a mistake, and panics.
+
+func (p *WorkerPool) Close() {
+	close(p.queue)
+	p.wg.Wait()
 }
 
 // We've set everything up, but now what we can do is
@@ -95,7 +298,7 @@ type Person struct {
 func NewPerson(name string) *Person {
 	return &Person{
 		Name:       name,
-		Observable: Observable{new(list.List)},
+		Observable: Observable{subs: new(list.List)},
 	}
 }
 
@@ -115,11 +318,106 @@ type DoctorService struct{}
 // <- We use this so we can implement the Observer interface
 
 func (d *DoctorService) Notify(data interface{}) {
-	fmt.Printf("A doctor has been called for %s", data.(string))
+	fmt.Printf("A doctor has been called for %s\n", data.(string))
 }
 
 // <- This is the method that gets called whenever a person falls ill.
 
+// Now, what if we want to keep an eye on a bunch of people?
+// We could subscribe to every single one of them, but we can
+// also merge several observables into a single one, so we only
+// ever have to subscribe once.
+
+// Since the events are now coming from different places,
+// every event gets tagged with the observable it came from.
+
+type TaggedEvent struct {
+	Source *Observable
+	Data   interface{}
+}
+
+// The merged observable is just an ordinary observable, and
+// we connect it to every source with a little forwarder.
+
+type forwarder struct {
+	source, target *Observable
+}
+
+func (f *forwarder) Notify(data interface{}) {
+	f.target.Fire(TaggedEvent{f.source, data})
+}
+
+func Merge(observables ...*Observable) (merged *Observable, stop func()) {
+	merged = &Observable{subs: new(list.List)}
+	var forwarders []*forwarder
+	seen := map[*Observable]bool{}
+	for _, o := range observables {
+		if seen[o] {
+			continue
+		}
+		seen[o] = true
+		f := &forwarder{o, merged}
+		o.Subscribe(f)
+		forwarders = append(forwarders, f)
+	}
+	return merged, func() {
+		for _, f := range forwarders {
+			f.source.Unsubscribe(f)
+		}
+	}
+}
+
+// <- If the same observable is passed in twice, we only
+//	  forward from it once, so nobody gets the same event twice.
+
+// <- Every source holds on to its forwarder, and through it
+//	  to the merged observable, for as long as the source lives.
+//	  So once we're done with the merged observable we call stop(),
+//	  and the sources forget all about it.
+
+// Subscribing and unsubscribing from the merged observable
+// works exactly as before, it's an Observable after all.
+
+type Hospital struct {
+	patients map[*Observable]string
+}
+
+func (h *Hospital) Notify(data interface{}) {
+	if e, ok := data.(TaggedEvent); ok {
+		fmt.Printf("Hospital: %s (ward %s) needs a doctor\n", e.Data, h.patients[e.Source])
+	}
+}
+
+// To see in which order the observers are called, we'll need
+// an observer which simply prints its name with every event.
+
+type namedObserver struct {
+	name string
+}
+
+func (n *namedObserver) Notify(data interface{}) {
+	fmt.Println(n.name, "got", data)
+}
+
+type slowObserver struct {
+	delay time.Duration
+}
+
+func (s *slowObserver) Notify(data interface{}) {
+	time.Sleep(s.delay)
+}
+
+// And to see that the lock does its job, one which simply
+// counts how many times it has been notified.
+
+type counter struct {
+	n atomic.Int64
+}
+
+func (c *counter) Notify(data interface{}) {
+	c.n.Add(1)
+}
+
 // So now that we have this whole scenarion,
 // let's see how we can actually use all of it.
 
@@ -134,4 +432,85 @@ func main() {
 	p.Subscribe(ds)
 
 	p.CatchACold()
+
+	q := NewPerson("Paul Atreides")
+	h := &Hospital{map[*Observable]string{
+		&p.Observable: "A",
+		&q.Observable: "B",
+	}}
+	merged, stop := Merge(&p.Observable, &q.Observable)
+	merged.Subscribe(h)
+
+	p.CatchACold()
+	q.CatchACold()
+	stop()
+	p.CatchACold() // <- only the doctor hears about this one
+
+	// Lots of goroutines firing, while others come and go.
+	o, steady := &Observable{subs: new(list.List)}, &counter{}
+	o.Subscribe(steady)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			o.Fire(nil)
+		}()
+		go func() {
+			defer wg.Done()
+			c := &counter{}
+			o.Subscribe(c)
+			o.Unsubscribe(c)
+		}()
+	}
+	wg.Wait()
+	fmt.Println("Steady observer got", steady.n.Load(), "of 100 events")
+
+	ordered := &Observable{subs: new(list.List)}
+	ordered.Subscribe(&namedObserver{"default"})
+	ordered.SubscribeWithPriority(&namedObserver{"low"}, -1)
+	ordered.SubscribeWithPriority(&namedObserver{"high"}, 10)
+	ordered.Fire("the news") // <- high, default, low
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ordered.SubscribeCtx(ctx, &namedObserver{"request"})
+	ordered.Fire("first event")
+	cancel()
+	ordered.Fire("second event") // <- the request is long gone
+
+	timed := &Observable{subs: new(list.List)}
+	timed.EnableMetrics()
+	timed.Subscribe(&slowObserver{20 * time.Millisecond})
+	timed.Fire(nil)
+	timed.Fire(nil)
+	fmt.Println("Slow observer noticed:", timed.LastFireDuration() >= 20*time.Millisecond,
+		"average at least 20ms:", timed.AverageFireDuration() >= 20*time.Millisecond)
+
+	busy, delivered := &Observable{subs: new(list.List)}, &counter{}
+	busy.Subscribe(delivered)
+	pool := NewWorkerPool(busy, 4, 8, Block)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				pool.Fire(j)
+			}
+		}()
+	}
+	wg.Wait()
+	pool.Close()
+	fmt.Println("Pool delivered", delivered.n.Load(), "of 1000 events")
+
+	slow := &Observable{subs: new(list.List)}
+	slow.Subscribe(&slowObserver{10 * time.Millisecond})
+	strict := NewWorkerPool(slow, 1, 1, Reject)
+	rejected := 0
+	for i := 0; i < 5; i++ {
+		if errors.Is(strict.Fire(i), ErrQueueFull) {
+			rejected++
+		}
+	}
+	strict.Close()
+	fmt.Println("Rejected some events:", rejected > 0)
 }
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newObservable() *Observable {
+	return &Observable{subs: new(list.List)}
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	name   string
+	events []interface{}
+	log    *[]string
+}
+
+func (r *recorder) Notify(data interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, data)
+	if r.log != nil {
+		*r.log = append(*r.log, r.name)
+	}
+}
+
+func (r *recorder) received() []interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]interface{}(nil), r.events...)
+}
+
+func TestMergeStopDetachesForwarders(t *testing.T) {
+	p, q := NewPerson("Leto"), NewPerson("Paul")
+	merged, stop := Merge(&p.Observable, &q.Observable)
+	r := &recorder{}
+	merged.Subscribe(r)
+
+	stop()
+	p.CatchACold()
+	q.CatchACold()
+
+	if events := r.received(); len(events) != 0 {
+		t.Fatalf("got %v after stop", events)
+	}
+	if n := len(p.snapshot()) + len(q.snapshot()); n != 0 {
+		t.Fatalf("%d forwarders still subscribed", n)
+	}
+}
+
+func TestMergeTagsEvents(t *testing.T) {
+	p, q := NewPerson("Leto"), NewPerson("Paul")
+	merged, stop := Merge(&p.Observable, &q.Observable, &p.Observable)
+	defer stop()
+	r := &recorder{}
+	merged.Subscribe(r)
+
+	p.CatchACold()
+	q.CatchACold()
+
+	events := r.received()
+	if len(events) != 2 {
+		t.Fatalf("got %v, a repeated source should only count once", events)
+	}
+	first, second := events[0].(TaggedEvent), events[1].(TaggedEvent)
+	if first.Source != &p.Observable || first.Data != "Leto" ||
+		second.Source != &q.Observable || second.Data != "Paul" {
+		t.Fatalf("got %+v, %+v", first, second)
+	}
+}
+
+func TestConcurrentSubscribeAndFire(t *testing.T) {
+	o, steady := newObservable(), &counter{}
+	o.Subscribe(steady)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			o.Fire(nil)
+		}()
+		go func() {
+			defer wg.Done()
+			c := &counter{}
+			o.Subscribe(c)
+			o.Unsubscribe(c)
+		}()
+	}
+	wg.Wait()
+
+	if n := steady.n.Load(); n != 100 {
+		t.Fatalf("steady observer got %d of 100 events", n)
+	}
+	if n := o.subs.Len(); n != 1 {
+		t.Fatalf("%d subscriptions left, want 1", n)
+	}
+}
+
+func TestPriorityOrder(t *testing.T) {
+	var log []string
+	o := newObservable()
+	o.Subscribe(&recorder{name: "default", log: &log})
+	o.SubscribeWithPriority(&recorder{name: "low", log: &log}, -1)
+	o.SubscribeWithPriority(&recorder{name: "high", log: &log}, 10)
+	o.Subscribe(&recorder{name: "default 2", log: &log})
+	o.Fire(nil)
+
+	want := []string{"high", "default", "default 2", "low"}
+	if len(log) != len(want) {
+		t.Fatalf("got %v, want %v", log, want)
+	}
+	for i := range want {
+		if log[i] != want[i] {
+			t.Fatalf("got %v, want %v", log, want)
+		}
+	}
+}
+
+func TestSubscribeCtxCancels(t *testing.T) {
+	o := newObservable()
+	r := &recorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+	o.SubscribeCtx(ctx, r)
+
+	o.Fire("first")
+	cancel()
+	o.Fire("second") // <- may still be subscribed, but mustn't hear it
+
+	if events := r.received(); len(events) != 1 || events[0] != "first" {
+		t.Fatalf("got %v", events)
+	}
+	for deadline := time.Now().Add(time.Second); len(o.snapshot()) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("still subscribed after the context was canceled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	o.Subscribe(r)
+	o.Unsubscribe(r)
+	if len(o.snapshot()) != 0 {
+		t.Fatal("Unsubscribe left the observer")
+	}
+}
+
+func TestFireMetrics(t *testing.T) {
+	o := newObservable()
+	o.Subscribe(&slowObserver{5 * time.Millisecond})
+	o.Fire(nil)
+	if o.LastFireDuration() != 0 || o.AverageFireDuration() != 0 {
+		t.Fatal("measured without metrics enabled")
+	}
+
+	o.EnableMetrics()
+	o.Fire(nil)
+	o.Fire(nil)
+	if o.LastFireDuration() < 5*time.Millisecond || o.AverageFireDuration() < 5*time.Millisecond {
+		t.Fatalf("last %v, average %v", o.LastFireDuration(), o.AverageFireDuration())
+	}
+}
+
+func TestWorkerPoolDeliversEverything(t *testing.T) {
+	busy, delivered := newObservable(), &counter{}
+	busy.Subscribe(delivered)
+	pool := NewWorkerPool(busy, 4, 8, Block)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if err := pool.Fire(j); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	pool.Close()
+
+	if n := delivered.n.Load(); n != 1000 {
+		t.Fatalf("delivered %d of 1000 events", n)
+	}
+}
+
+func TestWorkerPoolRejectsWhenFull(t *testing.T) {
+	release := make(chan struct{})
+	blocked := newObservable()
+	blocked.Subscribe(observerFunc(func(interface{}) { <-release }))
+	pool := NewWorkerPool(blocked, 1, 1, Reject)
+
+	// one event for the worker, one for the queue, the rest has no room
+	rejected := 0
+	for i := 0; i < 10; i++ {
+		if errors.Is(pool.Fire(i), ErrQueueFull) {
+			rejected++
+		}
+	}
+	close(release)
+	pool.Close()
+
+	if rejected < 8 {
+		t.Fatalf("rejected %d of 10 events, want at least 8", rejected)
+	}
+}
+
+type observerFunc func(data interface{})
+
+func (f observerFunc) Notify(data interface{}) {
+	f(data)
+}
@@ -15,10 +15,21 @@ package main
 import (
 	"container/list"
 	"fmt"
+	"strings"
+	"sync"
 )
 
 type Observable struct {
 	subs *list.List
+
+	// makes sure batches don't interleave, and that nobody
+	// subscribes halfway through one, see the end
+	fireMu sync.Mutex
+
+	// only used by replay observables, see the end
+	replay    bool
+	last      map[string]interface{}
+	lastOrder []string
 }
 
 type Observer interface {
@@ -26,31 +37,45 @@ type Observer interface {
 }
 
 func (o *Observable) Subscribe(x Observer) {
+	o.fireMu.Lock()
+	defer o.fireMu.Unlock()
 	o.subs.PushBack(x)
+	o.replayTo(x)
 }
 
 func (o *Observable) Unsubscribe(x Observer) {
+	o.fireMu.Lock()
+	defer o.fireMu.Unlock()
 	for z := o.subs.Front(); z != nil; z = z.Next() {
-		if z.Value.(Observer) == x {
+		if f, ok := z.Value.(*filteredObserver); ok && f.observer == x {
+			o.subs.Remove(z)
+		} else if z.Value.(Observer) == x {
 			o.subs.Remove(z)
 		}
 	}
 }
 
 func (o *Observable) Fire(data interface{}) {
+	o.fireMu.Lock()
+	defer o.fireMu.Unlock()
+	o.remember(data)
 	for z := o.subs.Front(); z != nil; z = z.Next() {
 		z.Value.(Observer).Notify(data)
 	}
 }
 
 type Person struct {
-	Observable
+	*Observable
 	age int
+
+	// keeps two SetAge calls from comparing and
+	// changing the age at the same time, see SetAge
+	setMu sync.Mutex
 }
 
 func NewPerson(age int) *Person {
 	return &Person{
-		Observable: Observable{new(list.List)},
+		Observable: &Observable{subs: new(list.List)},
 		age:        age,
 	}
 }
@@ -120,6 +145,8 @@ func (e *ElectoralRoll) Notify(data interface{}) {
 // Anoying, I know.
 
 func (p *Person) SetAge(age int) {
+	p.setMu.Lock()
+	defer p.setMu.Unlock()
 	if age == p.age {
 		return
 	}
@@ -127,17 +154,195 @@ func (p *Person) SetAge(age int) {
 	oldCanVote := p.CanVote()
 
 	p.age = age
-	p.Fire(PropertyChange{"Age", p.age})
+	changes := []PropertyChange{{"Age", p.age}}
 
 	if oldCanVote != p.CanVote() {
-		p.Fire(PropertyChange{"CanVote", p.CanVote()})
+		changes = append(changes, PropertyChange{"CanVote", p.CanVote()})
 	}
+	p.FireBatch(changes)
 }
 
+// <- Both changes go out together, more on that at the end.
+
+// <- And the lock is held from the comparison all the way to the
+//	  notification. Otherwise two goroutines could both read the
+//	  old voting status, and both of them would announce a change
+//	  that only happened once, or send their batches out of order.
+
 // <- So this is how we would implement the dependent property
 // 	  because essentially what we have is we have CanVote which is
 //	  a property which depends on the property or indeed the age field.
 
+// Now that one person fires several different property changes,
+// every observer gets all of them, even the ones it doesn't care about.
+// The electoral roll only cares about CanVote, and yet it has to
+// look at every single Age change as well.
+
+// What we can do is let the observable do the filtering for us.
+// An observer can subscribe to a single property, and we wrap it
+// into another observer which only passes on the matching changes.
+
+type filteredObserver struct {
+	observer     Observer
+	propertyName string
+}
+
+func (f *filteredObserver) Notify(data interface{}) {
+	if pc, ok := data.(PropertyChange); ok && pc.Name == f.propertyName {
+		f.observer.Notify(data)
+	}
+}
+
+func (f *filteredObserver) NotifyBatch(changes []PropertyChange) {
+	var matching []PropertyChange
+	for _, pc := range changes {
+		if pc.Name == f.propertyName {
+			matching = append(matching, pc)
+		}
+	}
+	if len(matching) > 0 {
+		deliverBatch(f.observer, matching)
+	}
+}
+
+// <- Anything that isn't a property change simply doesn't get through.
+
+func (o *Observable) SubscribeFiltered(x Observer, propertyName string) {
+	f := &filteredObserver{x, propertyName}
+	o.fireMu.Lock()
+	defer o.fireMu.Unlock()
+	o.subs.PushBack(f)
+	o.replayTo(f)
+}
+
+// And that's why Unsubscribe also has to look inside
+// the wrapper when looking for the observer to remove.
+
+// Let's have another observer which only cares about age.
+
+type AgeLogger struct{}
+
+func (a *AgeLogger) Notify(data interface{}) {
+	fmt.Println("Age changed to:", data.(PropertyChange).Value)
+}
+
+// Another problem is that whoever subscribes late misses out.
+// If the electoral roll starts watching after the person already
+// turned 18, it will never hear that they can vote.
+
+// So an observable can remember the last value that it fired,
+// and hand it over to anyone who subscribes later on.
+// With property changes, the last value of every property matters,
+// not just the very last change, so we keep one per property.
+
+func NewReplayObservable() *Observable {
+	return &Observable{
+		subs:   new(list.List),
+		replay: true,
+		last:   map[string]interface{}{},
+	}
+}
+
+// <- It hands out a pointer, and a person keeps hold of one,
+//	  since an observable has a mutex inside, and a copied mutex
+//	  doesn't guard anything the original one does.
+
+func (o *Observable) remember(data interface{}) {
+	if !o.replay {
+		return
+	}
+	key := ""
+	if pc, ok := data.(PropertyChange); ok {
+		key = pc.Name
+	}
+	if _, ok := o.last[key]; !ok {
+		o.lastOrder = append(o.lastOrder, key)
+	}
+	o.last[key] = data
+}
+
+func (o *Observable) replayTo(x Observer) {
+	if !o.replay {
+		return
+	}
+	for _, key := range o.lastOrder {
+		x.Notify(o.last[key])
+	}
+}
+
+// <- Anything that isn't a property change is simply
+//	  remembered as the last "anything else".
+
+// And a person can choose to be this kind of observable.
+
+func NewPersonWithReplay(age int) *Person {
+	return &Person{
+		Observable: NewReplayObservable(),
+		age:        age,
+	}
+}
+
+// And one more thing. When the age changes, the person sends out
+// both an Age and a CanVote change, and an observer which cares
+// about both would like to see them in that order, and together.
+// It shouldn't see the CanVote of one change and then the Age of
+// another, just because two goroutines happened to set the age.
+
+// So related changes go out as a batch. Every observer gets the
+// whole batch before the next one gets anything, and no other
+// batch can sneak in between.
+
+type BatchObserver interface {
+	Observer
+	NotifyBatch(changes []PropertyChange)
+}
+
+// <- Observers that want the batch as a whole can ask for it,
+//	  everybody else simply gets the changes one by one, in order.
+
+func deliverBatch(x Observer, changes []PropertyChange) {
+	if b, ok := x.(BatchObserver); ok {
+		b.NotifyBatch(changes)
+		return
+	}
+	for _, pc := range changes {
+		x.Notify(pc)
+	}
+}
+
+func (o *Observable) FireBatch(changes []PropertyChange) {
+	o.fireMu.Lock()
+	defer o.fireMu.Unlock()
+	for _, pc := range changes {
+		o.remember(pc)
+	}
+	for z := o.subs.Front(); z != nil; z = z.Next() {
+		deliverBatch(z.Value.(Observer), changes)
+	}
+}
+
+// <- Subscribing goes through the same lock, so a late subscriber
+//	  gets its replay either before a batch or after it, never a
+//	  mix of the two. The flip side is that an observer can't fire,
+//	  subscribe or unsubscribe on the same observable while being
+//	  notified, it would wait forever.
+
+type ChangeRecorder struct {
+	batches []string
+}
+
+func (c *ChangeRecorder) Notify(data interface{}) {
+	c.NotifyBatch([]PropertyChange{data.(PropertyChange)})
+}
+
+func (c *ChangeRecorder) NotifyBatch(changes []PropertyChange) {
+	var names []string
+	for _, pc := range changes {
+		names = append(names, pc.Name)
+	}
+	c.batches = append(c.batches, strings.Join(names, "+"))
+}
+
 // Going back to our scenario, let's connect everything together.
 // Ok, now this works, but what's the problem?
 // There's always something.
@@ -166,10 +371,29 @@ func (p *Person) SetAge(age int) {
 func main() {
 	p := NewPerson(0)
 	er := &ElectoralRoll{}
-	p.Subscribe(er)
+	p.SubscribeFiltered(er, "CanVote")
+	p.SubscribeFiltered(&AgeLogger{}, "Age")
 
 	for i := 10; i < 20; i++ {
-		fmt.Println("Setting age to:", i)
 		p.SetAge(i)
 	}
+
+	late := NewPersonWithReplay(17)
+	late.SetAge(18)
+	late.SubscribeFiltered(&ElectoralRoll{}, "CanVote") // <- still gets the news
+
+	recorder := &ChangeRecorder{}
+	voter := NewPerson(16)
+	voter.Subscribe(recorder)
+	var wg sync.WaitGroup
+	for age := 17; age <= 20; age++ {
+		wg.Add(1)
+		go func(age int) {
+			defer wg.Done()
+			voter.FireBatch([]PropertyChange{{"Age", age}, {"CanVote", age >= 18}})
+		}(age)
+	}
+	wg.Wait()
+	voter.SetAge(18)
+	fmt.Println("Batches:", recorder.batches)
 }
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+type changeLog struct {
+	changes []PropertyChange
+}
+
+func (c *changeLog) Notify(data interface{}) {
+	c.changes = append(c.changes, data.(PropertyChange))
+}
+
+func TestSubscribeFiltered(t *testing.T) {
+	p := NewPerson(16)
+	votes, ages := &changeLog{}, &changeLog{}
+	p.SubscribeFiltered(votes, "CanVote")
+	p.SubscribeFiltered(ages, "Age")
+
+	for age := 17; age <= 19; age++ {
+		p.SetAge(age)
+	}
+	if len(votes.changes) != 1 || votes.changes[0] != (PropertyChange{"CanVote", true}) {
+		t.Fatalf("votes: %v", votes.changes)
+	}
+	if len(ages.changes) != 3 {
+		t.Fatalf("ages: %v", ages.changes)
+	}
+
+	p.Unsubscribe(ages)
+	p.SetAge(20)
+	if len(ages.changes) != 3 {
+		t.Fatal("still notified after unsubscribing")
+	}
+}
+
+func TestReplayToLateSubscribers(t *testing.T) {
+	p := NewPersonWithReplay(17)
+	p.SetAge(18)
+	p.SetAge(19)
+
+	late := &changeLog{}
+	p.Subscribe(late)
+	want := []PropertyChange{{"Age", 19}, {"CanVote", true}}
+	if len(late.changes) != 2 || late.changes[0] != want[0] || late.changes[1] != want[1] {
+		t.Fatalf("got %v, want %v", late.changes, want)
+	}
+
+	filtered := &changeLog{}
+	p.SubscribeFiltered(filtered, "CanVote")
+	if len(filtered.changes) != 1 || filtered.changes[0] != want[1] {
+		t.Fatalf("filtered: %v", filtered.changes)
+	}
+
+	plain := &changeLog{}
+	NewPerson(19).Subscribe(plain)
+	if len(plain.changes) != 0 {
+		t.Fatal("a plain observable replayed something")
+	}
+}
+
+func TestBatchesDontInterleave(t *testing.T) {
+	voter := NewPerson(16)
+	recorder := &ChangeRecorder{}
+	voter.Subscribe(recorder)
+
+	var wg sync.WaitGroup
+	for age := 17; age <= 40; age++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			voter.FireBatch([]PropertyChange{{"Age", age}, {"CanVote", age >= 18}})
+		}()
+	}
+	wg.Wait()
+
+	if len(recorder.batches) != 24 {
+		t.Fatalf("%d batches, want 24", len(recorder.batches))
+	}
+	for _, b := range recorder.batches {
+		if b != "Age+CanVote" {
+			t.Fatalf("got %v", recorder.batches)
+		}
+	}
+}
+
+// Keeps the whole batches, values and all.
+
+type batchLog struct {
+	batches [][]PropertyChange
+}
+
+func (b *batchLog) Notify(data interface{}) {
+	b.NotifyBatch([]PropertyChange{data.(PropertyChange)})
+}
+
+func (b *batchLog) NotifyBatch(changes []PropertyChange) {
+	b.batches = append(b.batches, changes)
+}
+
+func TestConcurrentSetAge(t *testing.T) {
+	p := NewPerson(16)
+	log := &batchLog{}
+	p.Subscribe(log)
+
+	// Half of the goroutines keep setting 17, the other half 18,
+	// so the voting status flips back and forth all the time.
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				p.SetAge(17 + (i+j)%2)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Every reported change must really be a change, so the ages
+	// never repeat and the voting status always goes with the age.
+	age, canVote := 16, false
+	for _, b := range log.batches {
+		next := b[0].Value.(int)
+		if next == age {
+			t.Fatalf("age %d reported twice in a row", age)
+		}
+		if (next >= 18) != canVote {
+			if len(b) != 2 || b[1] != (PropertyChange{"CanVote", next >= 18}) {
+				t.Fatalf("age %d -> %d came with %v", age, next, b)
+			}
+			canVote = next >= 18
+		} else if len(b) != 1 {
+			t.Fatalf("age %d -> %d came with %v", age, next, b)
+		}
+		age = next
+	}
+	if age != p.Age() {
+		t.Fatalf("last reported age %d, but the person is %d", age, p.Age())
+	}
+}
+
+func TestReplayDoesntSplitBatches(t *testing.T) {
+	p := NewPersonWithReplay(10)
+	p.FireBatch([]PropertyChange{{"Age", 10}, {"CanVote", false}})
+
+	var wg sync.WaitGroup
+	logs := make([]*changeLog, 50)
+	for i := range logs {
+		logs[i] = &changeLog{}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			age := 10 + i
+			p.FireBatch([]PropertyChange{{"Age", age}, {"CanVote", age >= 18}})
+		}()
+		go func() {
+			defer wg.Done()
+			p.Subscribe(logs[i])
+		}()
+	}
+	wg.Wait()
+
+	for _, log := range logs {
+		age, canVote := log.changes[0].Value.(int), log.changes[1].Value.(bool)
+		if canVote != (age >= 18) {
+			t.Fatalf("replayed age %d together with CanVote %v", age, canVote)
+		}
+	}
+}
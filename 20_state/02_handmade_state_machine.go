@@ -14,9 +14,16 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // For example we can define states by just defining
@@ -39,6 +46,7 @@ const (
 	OffHook State = iota
 	Connecting
 	Connected
+	Talking
 	OnHold
 	OnHook
 )
@@ -56,6 +64,8 @@ func (s State) String() string {
 		return "Connecting"
 	case Connected:
 		return "Connected"
+	case Talking:
+		return "Talking"
 	case OnHold:
 		return "OnHold"
 	case OnHook:
@@ -130,16 +140,17 @@ var rules = map[State][]TriggerResult{
 	},
 	Connecting: {
 		{HungUp, OnHook},
-		{CallConnected, Connected},
+		{CallConnected, Talking},
 	},
 	Connected: {
-		{LeftMessage, OnHook},
 		{HungUp, OnHook},
+	},
+	Talking: {
+		{LeftMessage, OnHook},
 		{PlacedOnHold, OnHold},
 	},
 	OnHold: {
-		{TakenOffHold, Connected},
-		{HungUp, OnHook},
+		{TakenOffHold, Talking},
 	},
 }
 
@@ -148,9 +159,389 @@ var rules = map[State][]TriggerResult{
 //	  be possible to transition to more than one state
 //	  depending on the trigger.
 
+// Notice that Connected is no longer a state we actually
+// end up in. Instead it's a composite state, a parent of
+// Talking and OnHold, and the HungUp trigger is defined
+// only once, on the parent.
+
+// This is the idea behind statecharts, states can be nested,
+// and whatever the parent handles applies to all of its substates.
+// All we need to record is who the parent of each substate is.
+
+var parents = map[State]State{
+	Talking: Connected,
+	OnHold:  Connected,
+}
+
+// Now, to resolve a trigger we first look at the rules
+// of the current state, and if nothing there handles it
+// we walk up the hierarchy and try the parent, and then
+// the parent's parent and so on.
+
+func fire(rules map[State][]TriggerResult, state State, trigger Trigger) (State, bool) {
+	for s, ok := state, true; ok; s, ok = parents[s] {
+		for _, tr := range rules[s] {
+			if tr.Trigger == trigger {
+				return tr.State, true
+			}
+		}
+	}
+	return state, false
+}
+
+// And for the same reason, the triggers that are available
+// in some state are its own triggers plus everything
+// inherited from its ancestors.
+
+func availableTriggers(rules map[State][]TriggerResult, state State) []TriggerResult {
+	var result []TriggerResult
+	for s, ok := state, true; ok; s, ok = parents[s] {
+		result = append(result, rules[s]...)
+	}
+	return result
+}
+
+// With the rules being plain data, we can also ask questions
+// about the machine without running it. For example, which states
+// can we possibly end up in, starting from some state? Anything that
+// can't be reached from the initial state is probably a mistake.
+
+// We simply keep following every trigger until we stop finding
+// new states. Being in a substate also means being in its parent,
+// so parents count as reached, and their triggers apply as well.
+
+func ReachableStates(rules map[State][]TriggerResult, from State) []State {
+	reached := map[State]bool{}
+	queue := []State{from}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for s, ok := state, true; ok; s, ok = parents[s] {
+			if s != state {
+				reached[s] = true
+			}
+			for _, tr := range rules[s] {
+				if !reached[tr.State] {
+					reached[tr.State] = true
+					queue = append(queue, tr.State)
+				}
+			}
+		}
+	}
+
+	var result []State
+	for s := range reached {
+		result = append(result, s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// <- The starting state is only in there if we can come back to it.
+
 // Now that we have all of this we can now
 // build our state machine and orchestrate this.
 
+// But there's one more thing that real phones do.
+// Nobody waits in the Connecting state forever, after
+// a while the call just drops by itself.
+// So some transitions aren't caused by a trigger at all,
+// they simply happen once enough time has passed.
+
+// To be able to check that without actually waiting,
+// we don't ask the time package directly, we ask a clock.
+
+type Clock interface {
+	Now() time.Time
+}
+
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+type TimedTransition struct {
+	After time.Duration
+	State State
+}
+
+// Now we need somewhere to keep the current state together
+// with the time we entered it, so let's wrap it all up.
+
+type StateMachine struct {
+	mu      sync.Mutex
+	rules   map[State][]TriggerResult
+	state   State
+	entered time.Time
+	clock   Clock
+	timed   map[State]TimedTransition
+}
+
+func NewStateMachine(initial State, clock Clock) *StateMachine {
+	return &StateMachine{
+		rules:   rules,
+		state:   initial,
+		entered: clock.Now(),
+		clock:   clock,
+		timed:   map[State]TimedTransition{},
+	}
+}
+
+func (m *StateMachine) AddTimedTransition(from State, after time.Duration, to State) error {
+	if after <= 0 {
+		return fmt.Errorf("timed transition from %v to %v: %v is not in the future", from, to, after)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timed[from] = TimedTransition{after, to}
+	return nil
+}
+
+// <- The machine might be shared between goroutines, so
+//	  everything that touches it goes through the mutex.
+//	  And a transition that fires right away would fire forever,
+//	  so it has to wait for at least some time.
+
+// Whenever somebody looks at the machine we first check whether
+// we've been sitting in the current state for too long.
+// One timed transition can lead to another, hence the loop.
+
+func (m *StateMachine) Current() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current()
+}
+
+func (m *StateMachine) current() State {
+	now := m.clock.Now()
+	if !m.advance(now) {
+		m.skipCycles(now)
+		m.advance(now)
+	}
+	return m.state
+}
+
+func (m *StateMachine) advance(now time.Time) (settled bool) {
+	for hops := 0; ; hops++ {
+		tt, ok := m.timed[m.state]
+		if !ok || now.Sub(m.entered) < tt.After {
+			return true
+		}
+		if hops == len(m.timed) {
+			return false
+		}
+		m.state = tt.State
+		m.entered = m.entered.Add(tt.After)
+	}
+}
+
+// <- There can't be more hops in a row than there are timed
+//	  transitions, unless they go round in a circle. If they do,
+//	  and nobody looked at the machine for a long while, we don't
+//	  walk around the circle over and over again, we skip all the
+//	  full rounds at once and only walk the last one.
+
+func (m *StateMachine) skipCycles(now time.Time) {
+	var round time.Duration
+	state := m.state
+	for {
+		tt := m.timed[state]
+		round += tt.After
+		if state = tt.State; state == m.state {
+			break
+		}
+	}
+	m.entered = m.entered.Add(now.Sub(m.entered) / round * round)
+}
+
+// When a trigger doesn't apply to the current state, we don't
+// just want to say no, we want to say what would have worked,
+// so whoever is using the machine can tell the user about it.
+
+type ErrInvalidTransition struct {
+	State    State
+	Trigger  Trigger
+	triggers []Trigger
+}
+
+func newErrInvalidTransition(rules map[State][]TriggerResult, state State, trigger Trigger) *ErrInvalidTransition {
+	err := &ErrInvalidTransition{State: state, Trigger: trigger}
+	for _, tr := range availableTriggers(rules, state) {
+		err.triggers = append(err.triggers, tr.Trigger)
+	}
+	return err
+}
+
+func (e *ErrInvalidTransition) ValidTriggers() []Trigger {
+	return e.triggers
+}
+
+func (e *ErrInvalidTransition) Error() string {
+	valid := make([]string, len(e.triggers))
+	for i, t := range e.triggers {
+		valid[i] = t.String()
+	}
+	return fmt.Sprintf("cannot fire %s while %s, valid triggers are: %s",
+		e.Trigger, e.State, strings.Join(valid, ", "))
+}
+
+func (m *StateMachine) Fire(trigger Trigger) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := m.current()
+	next, ok := fire(m.rules, state, trigger)
+	if !ok {
+		return newErrInvalidTransition(m.rules, state, trigger)
+	}
+	m.state = next
+	m.entered = m.clock.Now()
+	return nil
+}
+
+// <- If the time ran out before the trigger arrived,
+//	  the trigger might not be valid anymore.
+
+// Checking the current state and moving to the next one happen
+// under a single lock. Otherwise two goroutines could both see
+// the same state, and both move on from it, as if the other
+// one never happened.
+
+// A phone call can take a while, and the process that
+// keeps track of it might get restarted in the meantime.
+// So we want to be able to save the current state somewhere
+// and pick up where we left off.
+
+// We store the state by its name rather than by its number,
+// so reordering the constants doesn't break anything we've saved.
+
+type savedState struct {
+	State string `json:"state"`
+}
+
+func (m *StateMachine) MarshalState() []byte {
+	data, _ := json.Marshal(savedState{m.Current().String()})
+	return data
+}
+
+// <- Marshaling a struct with a single string can't fail.
+
+// When restoring, we can't trust whatever we get,
+// so the name has to be one of the states we actually know.
+
+func parseState(name string) (State, error) {
+	for s := OffHook; s <= OnHook; s++ {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown state %q", name)
+}
+
+func (m *StateMachine) RestoreState(data []byte) error {
+	var saved savedState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	state, err := parseState(saved.State)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+	m.entered = m.clock.Now()
+	return nil
+}
+
+// <- The time spent in the state before the restart is lost,
+//	  any timed transition starts counting from scratch.
+
+// The rules are just a map, so nothing stops anybody from writing
+// a rule that leads to a state that doesn't exist, or forgetting the
+// one rule that gets us out. We'd rather find that out when the machine
+// is made than halfway through a phone call.
+
+func isDefined(s State) bool {
+	return s >= OffHook && s <= OnHook
+}
+
+func validateRules(rules map[State][]TriggerResult, initial, exit State) error {
+	for _, s := range []State{initial, exit} {
+		if !isDefined(s) {
+			return fmt.Errorf("state machine: undefined state %d", s)
+		}
+	}
+	if err := validateParents(parents); err != nil {
+		return err
+	}
+	for from, results := range rules {
+		if !isDefined(from) {
+			return fmt.Errorf("state machine: rules for undefined state %d", from)
+		}
+		for _, tr := range results {
+			if !isDefined(tr.State) {
+				return fmt.Errorf("state machine: %s on %s leads to undefined state %d",
+					tr.Trigger, from, tr.State)
+			}
+		}
+	}
+	if initial != exit && !slices.Contains(ReachableStates(rules, initial), exit) {
+		return fmt.Errorf("state machine: exit state %s can't be reached from %s", exit, initial)
+	}
+	return nil
+}
+
+// <- Map iteration has no order, so if there's more than one
+//	  mistake, which one gets reported may vary.
+
+// The parents are just a map as well, and they can go wrong in
+// a nastier way. If a state ends up being its own ancestor, walking
+// up the hierarchy never ends, and fire() simply hangs.
+
+func validateParents(parents map[State]State) error {
+	for child, parent := range parents {
+		if !isDefined(child) || !isDefined(parent) {
+			return fmt.Errorf("state machine: %d is a substate of %d, but not both are defined",
+				child, parent)
+		}
+		seen := map[State]bool{child: true}
+		for s, ok := parent, true; ok; s, ok = parents[s] {
+			if seen[s] {
+				return fmt.Errorf("state machine: %s is its own ancestor", s)
+			}
+			seen[s] = true
+		}
+	}
+	return nil
+}
+
+// <- The parents are checked first, since looking for the
+//	  reachable states walks up the hierarchy too.
+
+// And the validated machine is the very same engine, it just
+// refuses to be made out of rules that don't hold up.
+
+type ValidatedStateMachine struct {
+	*StateMachine
+	Exit State
+}
+
+func NewValidatedStateMachine(rules map[State][]TriggerResult, initial, exit State,
+	clock Clock) (*ValidatedStateMachine, error) {
+	if err := validateRules(rules, initial, exit); err != nil {
+		return nil, err
+	}
+	m := NewStateMachine(initial, clock)
+	m.rules = rules
+	return &ValidatedStateMachine{m, exit}, nil
+}
+
+func (v *ValidatedStateMachine) Done() bool {
+	return v.Current() == v.Exit
+}
+
 // Recap:
 // -> This is how we implement a state machine by hand
 // -> And this is how we do it in a more realistic setting,
@@ -158,25 +549,75 @@ var rules = map[State][]TriggerResult{
 // -> All we're doing here is we just have a bunch of constants,
 //	  and then we have a map which basically defines all the transition
 //	  rules that can happen inside a system
+// -> And with a parent map on the side, states can be nested,
+//	  so a trigger defined on a parent applies to all of its substates
+// -> Timed transitions need a notion of time, so they live in
+//	  a small engine which is driven by a clock
 
 func main() {
-	state, exitState := OffHook, OnHook
+	saved := NewStateMachine(Connected, RealClock{}).MarshalState()
+	restored := NewStateMachine(OffHook, RealClock{})
+	if err := restored.RestoreState(saved); err != nil {
+		fmt.Println(err)
+	}
+	fmt.Println("Restored", string(saved), "as", restored.Current(),
+		"with triggers", availableTriggers(rules, restored.Current()))
+	fmt.Println(restored.RestoreState([]byte(`{"state":"Ringing"}`)))
+	fmt.Println("Reachable from OffHook:", ReachableStates(rules, OffHook))
+
+	shared := NewStateMachine(OffHook, RealClock{})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(trigger Trigger) {
+			defer wg.Done()
+			shared.Fire(trigger)
+		}(Trigger(i % 6))
+	}
+	wg.Wait()
+	fmt.Println("After a burst of triggers the shared phone is:", shared.Current())
+
+	if _, err := NewValidatedStateMachine(rules, OffHook, OnHook, RealClock{}); err == nil {
+		fmt.Println("The phone rules are fine")
+	}
+	broken := map[State][]TriggerResult{
+		OffHook: {{CallDialed, State(42)}},
+	}
+	_, err := NewValidatedStateMachine(broken, OffHook, OnHook, RealClock{})
+	fmt.Println(err)
+
+	m, exitState := NewStateMachine(OffHook, RealClock{}), OnHook
+	if err := m.AddTimedTransition(Connecting, 0, OnHook); err != nil {
+		fmt.Println(err)
+	}
+	m.AddTimedTransition(Connecting, 30*time.Second, OnHook)
 	// <- when we reach exitState we're done effectively
 
-	for ok := true; ok; ok = state != exitState {
+	reader := bufio.NewReader(os.Stdin)
+	for ok := true; ok; ok = m.Current() != exitState {
+		state := m.Current()
 		fmt.Println("The phone is currently:", state)
 		fmt.Println("Select a trigger:")
 
-		for i := 0; i < len(rules[state]); i++ {
-			tr := rules[state][i]
-			fmt.Println(strconv.Itoa(i), ".", tr.Trigger)
+		valid := map[Trigger]bool{}
+		for _, tr := range availableTriggers(rules, state) {
+			fmt.Println(strconv.Itoa(int(tr.Trigger)), ".", tr.Trigger)
+			valid[tr.Trigger] = true
 		}
 
-		input, _, _ := bufio.NewReader(os.Stdin).ReadLine()
-		i, _ := strconv.Atoi(string(input))
+		input, _, err := reader.ReadLine()
+		if err == io.EOF {
+			return
+		}
+		i, err := strconv.Atoi(string(input))
+		if err != nil || !valid[Trigger(i)] {
+			fmt.Println("Please pick one of the triggers above")
+			continue
+		}
 
-		tr := rules[state][i]
-		state = tr.State
+		if err := m.Fire(Trigger(i)); err != nil {
+			fmt.Println(err) // <- the time might have run out while we were reading
+		}
 	}
 	fmt.Println("We're done using the phone")
 }
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func TestAddTimedTransitionRejectsNonPositive(t *testing.T) {
+	m := NewStateMachine(OffHook, &fakeClock{})
+	for _, after := range []time.Duration{0, -time.Second} {
+		if err := m.AddTimedTransition(Connecting, after, OnHook); err == nil {
+			t.Errorf("after %v: expected an error", after)
+		}
+	}
+}
+
+func TestTimedTransitionsChain(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	m := NewStateMachine(Connecting, clock)
+	m.AddTimedTransition(Connecting, time.Second, OnHold)
+	m.AddTimedTransition(OnHold, time.Second, OnHook)
+
+	clock.now = clock.now.Add(1500 * time.Millisecond)
+	if s := m.Current(); s != OnHold {
+		t.Fatalf("after 1.5s: %v, want OnHold", s)
+	}
+	clock.now = clock.now.Add(time.Second)
+	if s := m.Current(); s != OnHook {
+		t.Fatalf("after 2.5s: %v, want OnHook", s)
+	}
+}
+
+func TestTimedTransitionsCycle(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	m := NewStateMachine(Connecting, clock)
+	m.AddTimedTransition(Connecting, time.Second, OnHold)
+	m.AddTimedTransition(OnHold, 2*time.Second, Connecting)
+
+	// a million rounds of three seconds, plus one and a half
+	clock.now = clock.now.Add(3_000_000*time.Second + 1500*time.Millisecond)
+	if s := m.Current(); s != OnHold {
+		t.Fatalf("got %v, want OnHold", s)
+	}
+	clock.now = clock.now.Add(2 * time.Second)
+	if s := m.Current(); s != Connecting {
+		t.Fatalf("got %v, want Connecting", s)
+	}
+}
+
+func TestSubstatesInheritTransitions(t *testing.T) {
+	for _, state := range []State{Talking, OnHold} {
+		if next, ok := fire(rules, state, HungUp); !ok || next != OnHook {
+			t.Errorf("HungUp while %v: %v, %v", state, next, ok)
+		}
+	}
+	if _, ok := fire(rules, OffHook, HungUp); ok {
+		t.Error("OffHook inherited HungUp")
+	}
+
+	var triggers []Trigger
+	for _, tr := range availableTriggers(rules, OnHold) {
+		triggers = append(triggers, tr.Trigger)
+	}
+	if !reflect.DeepEqual(triggers, []Trigger{TakenOffHold, HungUp}) {
+		t.Errorf("triggers while OnHold: %v", triggers)
+	}
+}
+
+func TestFireRejectsInvalidTrigger(t *testing.T) {
+	m := NewStateMachine(Connecting, &fakeClock{})
+	err := m.Fire(PlacedOnHold)
+
+	var invalid *ErrInvalidTransition
+	if !errors.As(err, &invalid) {
+		t.Fatalf("got %v", err)
+	}
+	if invalid.State != Connecting || invalid.Trigger != PlacedOnHold ||
+		!reflect.DeepEqual(invalid.ValidTriggers(), []Trigger{HungUp, CallConnected}) {
+		t.Fatalf("got %+v", invalid)
+	}
+	want := "cannot fire PlacedOnHold while Connecting, valid triggers are: HungUp, CallConnected"
+	if err.Error() != want {
+		t.Fatalf("got %q", err.Error())
+	}
+	if m.Current() != Connecting {
+		t.Fatal("an invalid trigger changed the state")
+	}
+}
+
+func TestMarshalAndRestoreState(t *testing.T) {
+	m := NewStateMachine(OffHook, &fakeClock{})
+	m.Fire(CallDialed)
+	m.Fire(CallConnected)
+	data := m.MarshalState()
+
+	restored := NewStateMachine(OffHook, &fakeClock{})
+	if err := restored.RestoreState(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Current() != Talking {
+		t.Fatalf("restored %v, want Talking", restored.Current())
+	}
+
+	for _, bad := range []string{`{`, `{"state":"Dancing"}`} {
+		if err := restored.RestoreState([]byte(bad)); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+	if restored.Current() != Talking {
+		t.Fatal("a failed restore changed the state")
+	}
+}
+
+func TestConcurrentFire(t *testing.T) {
+	m := NewStateMachine(OffHook, &fakeClock{})
+	var fired atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if m.Fire(CallDialed) == nil {
+				fired.Add(1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			m.Current()
+		}()
+	}
+	wg.Wait()
+
+	if n := fired.Load(); n != 1 {
+		t.Fatalf("dialed %d times, want 1", n)
+	}
+	if m.Current() != Connecting {
+		t.Fatalf("ended up %v", m.Current())
+	}
+}
+
+func TestReachableStates(t *testing.T) {
+	tests := map[State][]State{
+		OffHook: {Connecting, Connected, Talking, OnHold, OnHook},
+		Talking: {Connected, Talking, OnHold, OnHook},
+		OnHook:  nil,
+	}
+	for from, want := range tests {
+		if got := ReachableStates(rules, from); !reflect.DeepEqual(got, want) {
+			t.Errorf("from %v: got %v, want %v", from, got, want)
+		}
+	}
+}
+
+func TestValidatedStateMachine(t *testing.T) {
+	m, err := NewValidatedStateMachine(rules, OffHook, OnHook, &fakeClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Fire(CallDialed)
+	m.Fire(HungUp)
+	if !m.Done() {
+		t.Fatalf("not done while %v", m.Current())
+	}
+
+	broken := []map[State][]TriggerResult{
+		{OffHook: {{CallDialed, State(42)}}},
+		{State(42): {{CallDialed, OnHook}}},
+		{OffHook: {{CallDialed, Connecting}}},
+	}
+	for _, r := range broken {
+		if _, err := NewValidatedStateMachine(r, OffHook, OnHook, &fakeClock{}); err == nil {
+			t.Errorf("%v: expected an error", r)
+		}
+	}
+	if _, err := NewValidatedStateMachine(rules, OffHook, State(-1), &fakeClock{}); err == nil {
+		t.Error("accepted an undefined exit state")
+	}
+}
+
+func TestValidateParents(t *testing.T) {
+	if err := validateParents(parents); err != nil {
+		t.Fatal(err)
+	}
+
+	broken := []map[State]State{
+		{Talking: State(42)},
+		{State(42): Connected},
+		{Talking: Talking},
+		{Talking: Connected, Connected: OnHold, OnHold: Talking},
+	}
+	for _, p := range broken {
+		if err := validateParents(p); err == nil {
+			t.Errorf("%v: expected an error", p)
+		}
+	}
+}
+
+func TestValidatedStateMachineChecksParents(t *testing.T) {
+	saved := parents
+	t.Cleanup(func() { parents = saved })
+	parents = map[State]State{Talking: Connected, Connected: Talking}
+
+	if _, err := NewValidatedStateMachine(rules, OffHook, OnHook, &fakeClock{}); err == nil {
+		t.Fatal("accepted a cycle in the parents")
+	}
+}
@@ -7,7 +7,12 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -79,6 +84,78 @@ func (h *HtmlListStrategy) AddListItem(builder *strings.Builder, item string) {
 	builder.WriteString("	<li>" + item + "</li>\n")
 }
 
+// Strategies can also be wrapped by other strategies.
+// For example, we can put a title above any kind of list
+// and a count of its items below it, without caring whether
+// the list itself is markdown or html.
+
+type DecoratedListStrategy struct {
+	inner ListStrategy
+	Title string
+	count int
+}
+
+func NewDecoratedListStrategy(inner ListStrategy, title string) *DecoratedListStrategy {
+	return &DecoratedListStrategy{inner: inner, Title: title}
+}
+
+func (d *DecoratedListStrategy) Start(builder *strings.Builder) {
+	d.count = 0
+	builder.WriteString(d.Title + "\n")
+	d.inner.Start(builder)
+}
+
+// <- Nobody tells us how many items there are,
+//	  so we count them ourselves as they come in.
+
+func (d *DecoratedListStrategy) AddListItem(builder *strings.Builder, item string) {
+	d.count++
+	d.inner.AddListItem(builder, item)
+}
+
+func (d *DecoratedListStrategy) End(builder *strings.Builder) {
+	d.inner.End(builder)
+	builder.WriteString(fmt.Sprintf("%d items\n", d.count))
+}
+
+// <- Since it keeps a count, and wraps a strategy which could
+//	  be keeping just about anything, it's a strategy with state,
+//	  and it says so.
+
+func (d *DecoratedListStrategy) Stateful() {}
+
+// A strategy doesn't even have to produce text.
+// This one simply keeps hold of the items, so that somebody
+// can get them back as actual data, rather than as a string.
+
+type JSONListStrategy struct {
+	items []string
+}
+
+func (j *JSONListStrategy) Start(builder *strings.Builder) {}
+func (j *JSONListStrategy) End(builder *strings.Builder)   {}
+
+func (j *JSONListStrategy) AddListItem(builder *strings.Builder, item string) {
+	j.items = append(j.items, item)
+}
+
+// <- The builder is simply ignored, instead we need a way
+//	  of getting the items out, which the text processor can look for.
+
+type StructuredListStrategy interface {
+	ListStrategy
+	Items() []string
+	Reset()
+}
+
+func (j *JSONListStrategy) Items() []string {
+	return j.items
+}
+
+func (j *JSONListStrategy) Reset() {
+	j.items = nil
+}
+
 // This is how we can both write markdown as well as html.
 // And now let's imagine that we have some sort of text processor.
 
@@ -89,12 +166,16 @@ func (h *HtmlListStrategy) AddListItem(builder *strings.Builder, item string) {
 type TextProcessor struct {
 	builder      strings.Builder
 	listStrategy ListStrategy
+
+	// optional caching of rendered lists, see the end
+	Memoize bool
+	cache   map[listKey]string
 }
 
 // Now, let's make a constructor for this.
 
 func NewTextProcessor(ls ListStrategy) *TextProcessor {
-	return &TextProcessor{strings.Builder{}, ls}
+	return &TextProcessor{builder: strings.Builder{}, listStrategy: ls}
 }
 
 // Notice that when we got started we defined a bunch of constants,
@@ -109,12 +190,17 @@ func (t *TextProcessor) SetOutputFormat(fmt OutputFormat) {
 	case HTML:
 		t.listStrategy = &HtmlListStrategy{}
 	}
+	t.cache = nil
 }
 
 // Now let's have a methond on the text processor where we take
 // a bunch of items and we append them using the selected strategy.
 
 func (t *TextProcessor) AppendList(items []string) {
+	if t.Memoize && cacheable(t.listStrategy) {
+		t.appendListCached(items)
+		return
+	}
 	s := t.listStrategy
 	s.Start(&t.builder)
 	for _, item := range items {
@@ -128,8 +214,14 @@ func (t *TextProcessor) AppendList(items []string) {
 
 func (t *TextProcessor) Reset() {
 	t.builder.Reset()
+	if s, ok := t.listStrategy.(StructuredListStrategy); ok {
+		s.Reset()
+	}
 }
 
+// <- Structured strategies keep their own output, so they
+//	  have to be reset as well.
+
 // And let's have a string representation where once again
 // we'll just going to implement the stringer interface.
 
@@ -137,6 +229,154 @@ func (t *TextProcessor) String() string {
 	return t.builder.String()
 }
 
+// And if the strategy we're using keeps its output as data,
+// we can get it out as JSON.
+
+var ErrNotStructured = errors.New("list strategy has no structured output")
+
+func (t *TextProcessor) JSON() ([]byte, error) {
+	s, ok := t.listStrategy.(StructuredListStrategy)
+	if !ok {
+		return nil, ErrNotStructured
+	}
+	items := s.Items()
+	if items == nil {
+		items = []string{}
+	}
+	return json.Marshal(items)
+}
+
+// <- No items is an empty array, not a null.
+
+// If the same list keeps getting rendered with the same strategy,
+// say in a template that's filled in over and over again, there's
+// no need to go through the strategy every single time.
+// We can remember what came out, keyed by the strategy and the items.
+
+type listKey struct {
+	strategy ListStrategy
+	items    [sha256.Size]byte
+}
+
+func (t *TextProcessor) appendListCached(items []string) {
+	key := listKey{t.listStrategy, hashItems(items)}
+
+	output, ok := t.cache[key]
+	if !ok {
+		sb := strings.Builder{}
+		s := t.listStrategy
+		s.Start(&sb)
+		for _, item := range items {
+			s.AddListItem(&sb, item)
+		}
+		s.End(&sb)
+		output = sb.String()
+
+		if t.cache == nil {
+			t.cache = map[listKey]string{}
+		}
+		t.cache[key] = output
+	}
+	t.builder.WriteString(output)
+}
+
+// The items are hashed one after another, each one preceded by
+// its length, so that ["ab", "c"] and ["a", "bc"] don't end up
+// with the same key.
+
+func hashItems(items []string) [sha256.Size]byte {
+	h := sha256.New()
+	var size [8]byte
+	for _, item := range items {
+		binary.BigEndian.PutUint64(size[:], uint64(len(item)))
+		h.Write(size[:])
+		h.Write([]byte(item))
+	}
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// <- Different items or a different strategy give us a different key,
+//	  so there's nothing to invalidate. But this only works for
+//	  strategies whose output depends on nothing but the items, which
+//	  is why it's something we have to ask for.
+
+// And even when we ask for it, some strategies simply can't be
+// memoized. A structured strategy has to see every item, otherwise
+// its Items() would be missing the ones that came from the cache,
+// and a strategy which keeps state of its own would never get to
+// update it. The latter can tell us about it:
+
+type StatefulListStrategy interface {
+	ListStrategy
+	Stateful()
+}
+
+func cacheable(s ListStrategy) bool {
+	switch s.(type) {
+	case StructuredListStrategy, StatefulListStrategy:
+		return false
+	}
+	return true
+}
+
+// <- Both of them simply go through the strategy every time,
+//	  as if Memoize was never set.
+
+// To see that for ourselves, we can wrap a strategy and count
+// how many items actually went through it.
+
+type countingListStrategy struct {
+	ListStrategy
+	items int
+}
+
+func (c *countingListStrategy) AddListItem(builder *strings.Builder, item string) {
+	c.items++
+	c.ListStrategy.AddListItem(builder, item)
+}
+
+// A strategy doesn't have to be about how things look.
+// It can just as well decide the order in which they come,
+// and the text processor doesn't need to know how that's decided.
+
+type SortStrategy interface {
+	Less(a, b string) bool
+}
+
+type Alphabetical struct{}
+
+func (Alphabetical) Less(a, b string) bool {
+	return a < b
+}
+
+type ByLength struct{}
+
+func (ByLength) Less(a, b string) bool {
+	return len(a) < len(b)
+}
+
+// <- Items of the same length keep the order they came in.
+
+type Unsorted struct{}
+
+func (Unsorted) Less(a, b string) bool {
+	return false
+}
+
+func (t *TextProcessor) AppendSortedList(items []string, cmp SortStrategy) {
+	sorted := append([]string(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return cmp.Less(sorted[i], sorted[j])
+	})
+	t.AppendList(sorted)
+}
+
+// <- We sort a copy, the caller's items stay the way they were.
+//	  And the sorting strategy and the list strategy have nothing to
+//	  do with each other, so any order goes with any format.
+
 // Finaly, we can take a look how all of this works.
 
 // Recap:
@@ -158,4 +398,34 @@ func main() {
 	tp.SetOutputFormat(HTML)
 	tp.AppendList([]string{"foo", "bar", "baz"})
 	fmt.Println(tp)
+
+	tp = NewTextProcessor(NewDecoratedListStrategy(&MarkdownListStrategy{}, "Things:"))
+	tp.AppendList([]string{"foo", "bar", "baz"})
+	fmt.Println(tp)
+
+	tp = NewTextProcessor(&JSONListStrategy{})
+	tp.AppendList([]string{"foo", "bar", "baz"})
+	if data, err := tp.JSON(); err == nil {
+		fmt.Println(string(data))
+	}
+	tp.Memoize = true
+	tp.AppendList([]string{"foo", "bar", "baz"})
+	if data, err := tp.JSON(); err == nil {
+		fmt.Println("Memoized, but still:", string(data))
+	}
+
+	counting := &countingListStrategy{ListStrategy: &MarkdownListStrategy{}}
+	tp = NewTextProcessor(counting)
+	tp.Memoize = true
+	for i := 0; i < 3; i++ {
+		tp.AppendList([]string{"foo", "bar", "baz"})
+	}
+	fmt.Println("Items rendered:", counting.items, "for 9 items appended")
+
+	fruit := []string{"pear", "fig", "banana", "apple"}
+	for _, order := range []SortStrategy{Alphabetical{}, ByLength{}, Unsorted{}} {
+		tp = NewTextProcessor(&MarkdownListStrategy{})
+		tp.AppendSortedList(fruit, order)
+		fmt.Printf("%T:\n%s", order, tp)
+	}
 }
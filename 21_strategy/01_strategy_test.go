@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOutputFormats(t *testing.T) {
+	tp := NewTextProcessor(&MarkdownListStrategy{})
+	tp.AppendList([]string{"foo", "bar"})
+	if got, want := tp.String(), " * foo\n * bar\n"; got != want {
+		t.Errorf("markdown: got %q, want %q", got, want)
+	}
+
+	tp.Reset()
+	tp.SetOutputFormat(HTML)
+	tp.AppendList([]string{"foo", "bar"})
+	if got, want := tp.String(), "<ul>\n\t<li>foo</li>\n\t<li>bar</li>\n</ul>\n"; got != want {
+		t.Errorf("html: got %q, want %q", got, want)
+	}
+}
+
+func TestDecoratedListStrategy(t *testing.T) {
+	tp := NewTextProcessor(NewDecoratedListStrategy(&MarkdownListStrategy{}, "Things:"))
+	tp.AppendList([]string{"foo", "bar"})
+	tp.AppendList([]string{"baz"})
+
+	want := "Things:\n * foo\n * bar\n2 items\nThings:\n * baz\n1 items\n"
+	if got := tp.String(); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tp := NewTextProcessor(&JSONListStrategy{})
+	if data, err := tp.JSON(); err != nil || string(data) != "[]" {
+		t.Fatalf("empty list: %s, %v", data, err)
+	}
+
+	tp.AppendList([]string{"foo", "bar"})
+	if data, err := tp.JSON(); err != nil || string(data) != `["foo","bar"]` {
+		t.Fatalf("got %s, %v", data, err)
+	}
+
+	tp.Reset()
+	if data, _ := tp.JSON(); string(data) != "[]" {
+		t.Fatalf("after reset: %s", data)
+	}
+
+	tp = NewTextProcessor(&MarkdownListStrategy{})
+	if _, err := tp.JSON(); !errors.Is(err, ErrNotStructured) {
+		t.Fatalf("markdown: got %v, want ErrNotStructured", err)
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	counting := &countingListStrategy{ListStrategy: &MarkdownListStrategy{}}
+	tp := NewTextProcessor(counting)
+	tp.Memoize = true
+	for i := 0; i < 3; i++ {
+		tp.AppendList([]string{"foo", "bar", "baz"})
+	}
+	if counting.items != 3 {
+		t.Fatalf("rendered %d items, want 3", counting.items)
+	}
+	if got, want := tp.String(), " * foo\n * bar\n * baz\n"; got != want+want+want {
+		t.Fatalf("got %q", got)
+	}
+
+	tp.AppendList([]string{"ab", "c"})
+	tp.AppendList([]string{"a", "bc"})
+	if counting.items != 7 {
+		t.Fatalf("different lists shared a cache entry, rendered %d items", counting.items)
+	}
+}
+
+func TestMemoizeBypassesStatefulStrategies(t *testing.T) {
+	tp := NewTextProcessor(&JSONListStrategy{})
+	tp.Memoize = true
+	tp.AppendList([]string{"foo"})
+	tp.AppendList([]string{"foo"})
+	if data, _ := tp.JSON(); string(data) != `["foo","foo"]` {
+		t.Fatalf("json: got %s", data)
+	}
+
+	tp = NewTextProcessor(NewDecoratedListStrategy(&MarkdownListStrategy{}, "Things:"))
+	tp.Memoize = true
+	tp.AppendList([]string{"foo"})
+	tp.AppendList([]string{"foo"})
+	if got, want := tp.String(), "Things:\n * foo\n1 items\n"; got != want+want {
+		t.Fatalf("decorated: got %q", got)
+	}
+}
+
+func TestHashItems(t *testing.T) {
+	if hashItems([]string{"ab", "c"}) == hashItems([]string{"a", "bc"}) {
+		t.Error("item boundaries are not part of the hash")
+	}
+	if hashItems([]string{"foo"}) != hashItems([]string{"foo"}) {
+		t.Error("the same items hash differently")
+	}
+}
+
+func TestAppendSortedList(t *testing.T) {
+	fruit := []string{"pear", "fig", "banana", "apple"}
+	tests := []struct {
+		order SortStrategy
+		want  []string
+	}{
+		{Alphabetical{}, []string{"apple", "banana", "fig", "pear"}},
+		{ByLength{}, []string{"fig", "pear", "apple", "banana"}},
+		{Unsorted{}, []string{"pear", "fig", "banana", "apple"}},
+	}
+	for _, tt := range tests {
+		tp := NewTextProcessor(&JSONListStrategy{})
+		tp.AppendSortedList(fruit, tt.order)
+		if got := tp.listStrategy.(*JSONListStrategy).Items(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%T: got %v, want %v", tt.order, got, tt.want)
+		}
+	}
+	if fruit[0] != "pear" {
+		t.Error("sorting changed the caller's slice")
+	}
+}
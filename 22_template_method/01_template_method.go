@@ -23,29 +23,156 @@ import "fmt"
 // But in order to do this, we also need some sort of
 // interface for what parts of the game we're interested in.
 
-type Game interface {
+type Game[R any] interface {
 	Start()
 	TakeTurn()
 	HaveWinner() bool
-	WinningPlayer() int
+	Result() R
 }
 
+// <- Not every game ends with just a winning player, some of them
+//	  end with a score, a pot, or whatever else, so the game itself
+//	  decides what its result looks like.
+
 // Now that we have this interface, we can write
 // a template method which is simply a skeleton algorithm
 // which makes use it.
 
-func PlayGame(g Game) {
-	g.Start()
-	for !g.HaveWinner() {
-		g.TakeTurn()
+func PlayGame[R any](g Game[R], sinks ...EventSink) R {
+	r := NewGameRunner(g, sinks...)
+	for !r.Step() {
 	}
-	fmt.Printf("Player %d wins.\n", g.WinningPlayer())
+	return g.Result()
 }
 
 // <- So the idea here is that we simply use the interface
 //	  and invoke the interface members in the exact order in
 //	  which we want to define the algorithm.
 
+// Well, almost. A game can go on for quite a while, and whoever
+// is showing it to the user might want to go one turn at a time,
+// or stop for a bit and carry on later. So the algorithm itself
+// lives in a runner which remembers where it is, and every Step()
+// moves the game forward by a single turn.
+
+type GameRunner[R any] struct {
+	game    Game[R]
+	sinks   []EventSink
+	turn    int
+	started bool
+	paused  bool
+	done    bool
+}
+
+func NewGameRunner[R any](g Game[R], sinks ...EventSink) *GameRunner[R] {
+	return &GameRunner[R]{game: g, sinks: sinks}
+}
+
+func (r *GameRunner[R]) Step() (done bool) {
+	if r.done {
+		return true
+	}
+	if !r.started {
+		r.game.Start()
+		r.started = true
+	}
+
+	if !r.game.HaveWinner() {
+		r.turn++
+		player := currentPlayer(r.game)
+		r.game.TakeTurn()
+		emit(r.sinks, GameEvent{TurnTaken, r.turn, player})
+	}
+
+	if r.game.HaveWinner() {
+		fmt.Printf("Game over: %+v\n", r.game.Result())
+		emit(r.sinks, GameEvent{GameWon, 0, winner(r.game)})
+		r.done = true
+	}
+	return r.done
+}
+
+// <- It's the very same skeleton, start, turns until there's
+//	  a winner, and the winner, it's just cut into pieces.
+
+// And to let the runner play on its own until somebody,
+// say one of the sinks, tells it to wait:
+
+func (r *GameRunner[R]) Run() (done bool) {
+	for !r.paused && !r.done {
+		r.Step()
+	}
+	return r.done
+}
+
+func (r *GameRunner[R]) Pause() {
+	r.paused = true
+}
+
+func (r *GameRunner[R]) Resume() (done bool) {
+	r.paused = false
+	return r.Run()
+}
+
+// Printing is fine, but sometimes we want an actual record
+// of what happened, so we can replay the game later on.
+// So the skeleton can also report a structured event for every
+// turn and one for the winner, to whoever is interested.
+
+type EventKind int
+
+const (
+	TurnTaken EventKind = iota
+	GameWon
+)
+
+type GameEvent struct {
+	Kind   EventKind
+	Turn   int
+	Player int
+}
+
+type EventSink func(e GameEvent)
+
+func emit(sinks []EventSink, e GameEvent) {
+	for _, sink := range sinks {
+		sink(e)
+	}
+}
+
+// <- The sinks are optional, so existing callers of PlayGame
+//	  don't have to change at all.
+
+// The Game interface has no idea whose turn it is, and we don't
+// want to force every game to tell us, so a game can opt in.
+// If it doesn't, the player in the events is simply -1.
+
+type PlayerTracker interface {
+	CurrentPlayer() int
+}
+
+func currentPlayer(g any) int {
+	if p, ok := g.(PlayerTracker); ok {
+		return p.CurrentPlayer()
+	}
+	return -1
+}
+
+// Whose turn it is right now says nothing about who won, though.
+// In a card game the last card can go to the loser, so the winner
+// is another thing a game has to tell us about on its own.
+
+type WinnerReporter interface {
+	Winner() int
+}
+
+func winner(g any) int {
+	if w, ok := g.(WinnerReporter); ok {
+		return w.Winner()
+	}
+	return -1
+}
+
 // Now that we have this, we can make the actual game,
 // a game of chess for example.
 // Adn of course, here we'll not going to implement all
@@ -60,6 +187,8 @@ type chess struct {
 }
 
 // Since chess is a game, it must implement Game interface.
+// All we want to know at the end of it is who won, so the
+// result is simply the number of the winning player.
 
 func (c *chess) Start() {
 	fmt.Println("Starting a new game of chess.")
@@ -75,11 +204,19 @@ func (c *chess) HaveWinner() bool {
 	return c.turn == c.maxTurns
 }
 
-func (c *chess) WinningPlayer() int {
+func (c *chess) Result() int {
+	return c.currentPlayer
+}
+
+func (c *chess) CurrentPlayer() int {
 	return c.currentPlayer
 }
 
-func NewGameOfChess() Game {
+func (c *chess) Winner() int {
+	return c.Result()
+}
+
+func NewGameOfChess() Game[int] {
 	return &chess{1, 10, 0}
 }
 
@@ -87,6 +224,60 @@ func NewGameOfChess() Game {
 //	  and that way we can sort of hide all the internals
 //	  of the chess struct we've created.
 
+// A card game, on the other hand, is about points.
+// Every turn the current player draws a card and adds it to their
+// score, and once the deck runs out, whoever has more points wins.
+
+type CardResult struct {
+	Winner int
+	Score  int
+}
+
+type cardGame struct {
+	deck          []int
+	scores        [2]int
+	currentPlayer int
+}
+
+func (c *cardGame) Start() {
+	fmt.Println("Dealing a new game of cards.")
+}
+
+func (c *cardGame) TakeTurn() {
+	card := c.deck[0]
+	c.deck = c.deck[1:]
+	c.scores[c.currentPlayer] += card
+	fmt.Printf("Player %d draws %d\n", c.currentPlayer, card)
+	c.currentPlayer = 1 - c.currentPlayer
+}
+
+func (c *cardGame) HaveWinner() bool {
+	return len(c.deck) == 0
+}
+
+func (c *cardGame) Result() CardResult {
+	winner := 0
+	if c.scores[1] > c.scores[0] {
+		winner = 1
+	}
+	return CardResult{Winner: winner, Score: c.scores[winner]}
+}
+
+func (c *cardGame) CurrentPlayer() int {
+	return c.currentPlayer
+}
+
+func (c *cardGame) Winner() int {
+	return c.Result().Winner
+}
+
+func NewCardGame(deck ...int) Game[CardResult] {
+	return &cardGame{deck: deck}
+}
+
+// <- Same skeleton, same PlayGame(), but this time
+//	  what comes out of it is a CardResult instead of an int.
+
 // Recap:
 // -> Essentially the template method is a skeleton algorithm
 // -> We can see that with PlayGame() method we're using the abstract
@@ -102,5 +293,25 @@ func NewGameOfChess() Game {
 
 func main() {
 	chess := NewGameOfChess()
-	PlayGame(chess)
+	fmt.Println("Winner:", PlayGame(chess))
+
+	var log []GameEvent
+	PlayGame(NewGameOfChess(), func(e GameEvent) {
+		log = append(log, e)
+	})
+	fmt.Printf("Logged %d events, the last one being %+v\n", len(log), log[len(log)-1])
+
+	var runner *GameRunner[int]
+	runner = NewGameRunner(NewGameOfChess(), func(e GameEvent) {
+		if e.Turn == 3 {
+			runner.Pause()
+		}
+	})
+	fmt.Println("Done after running:", runner.Run())
+	runner.Step()
+	fmt.Println("Done after resuming:", runner.Resume(),
+		"same winner:", runner.game.Result() == chess.Result())
+
+	result := PlayGame(NewCardGame(7, 3, 10, 2, 5, 9))
+	fmt.Printf("Player %d wins the card game with %d points\n", result.Winner, result.Score)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlayGameEvents(t *testing.T) {
+	var log []GameEvent
+	winner := PlayGame(NewGameOfChess(), func(e GameEvent) {
+		log = append(log, e)
+	})
+
+	if len(log) != 10 {
+		t.Fatalf("got %d events, want 9 turns and a win", len(log))
+	}
+	for i, e := range log[:9] {
+		if e != (GameEvent{TurnTaken, i + 1, i % 2}) {
+			t.Errorf("event %d: %+v", i, e)
+		}
+	}
+	if last := log[9]; last != (GameEvent{GameWon, 0, winner}) {
+		t.Errorf("last event %+v, winner %d", last, winner)
+	}
+}
+
+// A game which doesn't tell us whose turn it is, nor who won.
+
+type anonymousGame struct {
+	Game[int]
+}
+
+func TestEventsWithoutPlayerTracker(t *testing.T) {
+	var players []int
+	PlayGame(anonymousGame{NewGameOfChess()}, func(e GameEvent) {
+		players = append(players, e.Player)
+	})
+	for i, p := range players {
+		if p != -1 {
+			t.Fatalf("event %d has player %d, want -1", i, p)
+		}
+	}
+}
+
+func TestCardGameWonEventNamesWinner(t *testing.T) {
+	// Five cards, so player 0 draws the last one and player 1 is up
+	// next, but player 0 won with 7+10+5 against 3+2.
+	var last GameEvent
+	result := PlayGame(NewCardGame(7, 3, 10, 2, 5), func(e GameEvent) {
+		last = e
+	})
+	if result.Winner != 0 {
+		t.Fatalf("got %+v", result)
+	}
+	if last != (GameEvent{GameWon, 0, 0}) {
+		t.Fatalf("last event %+v, want player 0 to win", last)
+	}
+}
+
+func TestGameRunnerPauseAndResume(t *testing.T) {
+	var turns []int
+	var runner *GameRunner[int]
+	runner = NewGameRunner(NewGameOfChess(), func(e GameEvent) {
+		if e.Kind == TurnTaken {
+			turns = append(turns, e.Turn)
+		}
+		if e.Turn == 3 {
+			runner.Pause()
+		}
+	})
+
+	if runner.Run() || len(turns) != 3 {
+		t.Fatalf("paused after %d turns", len(turns))
+	}
+	if runner.Step() || len(turns) != 4 {
+		t.Fatalf("stepped to %d turns", len(turns))
+	}
+	if !runner.Resume() || len(turns) != 9 {
+		t.Fatalf("resumed to %d turns", len(turns))
+	}
+	if !runner.Step() || len(turns) != 9 {
+		t.Fatal("stepped past the end of the game")
+	}
+	if !reflect.DeepEqual(turns, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}) {
+		t.Fatalf("turns %v", turns)
+	}
+}
+
+func TestCardGameResult(t *testing.T) {
+	result := PlayGame(NewCardGame(7, 3, 10, 2, 5, 9))
+	if result != (CardResult{Winner: 0, Score: 22}) {
+		t.Fatalf("got %+v", result)
+	}
+}
@@ -22,6 +22,7 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -126,6 +127,8 @@ type Expression interface {
 type ExpressionVisitor interface {
 	VisitDoubleExpression(e *DoubleExpression)
 	VisitAdditionExpression(e *AdditionExpression)
+	VisitDivisionExpression(e *DivisionExpression)
+	VisitVariableExpression(e *VariableExpression)
 }
 
 // Now that we have this interface, what we can do is
@@ -153,7 +156,18 @@ func (a *AdditionExpression) Accept(ev ExpressionVisitor) {
 // And we need some demo, we need some sort of printer.
 
 type ExpressionPrinter struct {
-	sb strings.Builder
+	sb *strings.Builder
+
+	// optional depth limit, see below
+	maxDepth, depth int
+	truncated       bool
+
+	// optional formatting, also below
+	opts PrintOptions
+
+	// optional source map, at the very end
+	mapping bool
+	spans   []SourceSpan
 }
 
 // When it comes to visiting the different kinds
@@ -161,13 +175,45 @@ type ExpressionPrinter struct {
 // that visitor interface.
 
 func (ep *ExpressionPrinter) VisitDoubleExpression(e *DoubleExpression) {
+	defer ep.mark(e, ep.sb.Len())
 	ep.sb.WriteString(fmt.Sprintf("%g", e.value))
 }
 
 func (ep *ExpressionPrinter) VisitAdditionExpression(e *AdditionExpression) {
+	defer ep.mark(e, ep.sb.Len())
+	if ep.maxDepth > 0 && ep.depth >= ep.maxDepth {
+		ep.sb.WriteString("…")
+		ep.truncated = true
+		return
+	}
+	ep.depth++
+	defer func() { ep.depth-- }()
+
 	ep.sb.WriteRune('(')
 	e.left.Accept(ep) // <- this is the place where double jump magic happens
-	ep.sb.WriteRune('+')
+	ep.writeOperator('+')
+	e.right.Accept(ep)
+	ep.sb.WriteRune(')')
+}
+
+func (ep *ExpressionPrinter) VisitVariableExpression(e *VariableExpression) {
+	defer ep.mark(e, ep.sb.Len())
+	ep.sb.WriteString(e.name)
+}
+
+func (ep *ExpressionPrinter) VisitDivisionExpression(e *DivisionExpression) {
+	defer ep.mark(e, ep.sb.Len())
+	if ep.maxDepth > 0 && ep.depth >= ep.maxDepth {
+		ep.sb.WriteString("…")
+		ep.truncated = true
+		return
+	}
+	ep.depth++
+	defer func() { ep.depth-- }()
+
+	ep.sb.WriteRune('(')
+	e.left.Accept(ep)
+	ep.writeOperator('/')
 	e.right.Accept(ep)
 	ep.sb.WriteRune(')')
 }
@@ -193,9 +239,68 @@ func (ep *ExpressionPrinter) VisitAdditionExpression(e *AdditionExpression) {
 // let's just make a constructor for the new Expression Printers.
 
 func NewExpressionPrinter() *ExpressionPrinter {
-	return &ExpressionPrinter{strings.Builder{}}
+	return &ExpressionPrinter{sb: &strings.Builder{}}
+}
+
+// <- The builder is a pointer so that a printer can also write
+//	  into a builder that somebody else owns.
+
+// If we print lots of expressions one after another, say into
+// a single report, there's no need for every one of them to get its
+// own printer and its own builder, they can all go into the same one.
+
+func PrintInto(e Expression, sb *strings.Builder) {
+	e.Accept(&ExpressionPrinter{sb: sb})
+}
+
+// And if the expression comes from somewhere we don't trust,
+// it might be nested so deep that printing it blows the stack.
+// So we can also make a printer which stops descending at some
+// depth and just writes … instead.
+
+func NewDepthLimitedPrinter(maxDepth int) *ExpressionPrinter {
+	return &ExpressionPrinter{sb: &strings.Builder{}, maxDepth: maxDepth}
+}
+
+// <- A limit of 0 means no limit at all.
+
+func (ep *ExpressionPrinter) Truncated() bool {
+	return ep.truncated
 }
 
+// Different places want the same expression to look different.
+// A terminal is fine with (1+2), a report would rather have (1 + 2),
+// and somebody might prefer proper math symbols over * and /.
+
+type PrintOptions struct {
+	SpaceAroundOperators bool
+	MathSymbols          bool
+}
+
+// <- The zero value is the compact style we've had all along.
+
+func NewFormattedPrinter(opts PrintOptions) *ExpressionPrinter {
+	return &ExpressionPrinter{sb: &strings.Builder{}, opts: opts}
+}
+
+var mathSymbols = map[rune]rune{'*': '×', '/': '÷'}
+
+func (ep *ExpressionPrinter) writeOperator(op rune) {
+	if symbol, ok := mathSymbols[op]; ok && ep.opts.MathSymbols {
+		op = symbol
+	}
+	if ep.opts.SpaceAroundOperators {
+		ep.sb.WriteRune(' ')
+		ep.sb.WriteRune(op)
+		ep.sb.WriteRune(' ')
+	} else {
+		ep.sb.WriteRune(op)
+	}
+}
+
+// <- Division gets its ÷ from here, and the moment somebody
+//	  adds a multiplication it gets to use the symbols for free.
+
 // Let's also implement the stringer interface on
 // an Expression Printer.
 
@@ -223,6 +328,30 @@ func (ep *ExpressionPrinter) String() string {
 // where we could actually forger to handle a Substraction Expression
 // and everything would still pass.
 
+// And that's exactly what happened once we wanted division.
+
+type DivisionExpression struct {
+	left, right Expression
+}
+
+func (d *DivisionExpression) Accept(ev ExpressionVisitor) {
+	ev.VisitDivisionExpression(d)
+}
+
+// <- The compiler made us add VisitDivisionExpression to every
+//	  single visitor in this file, the printer above included.
+
+// And the same thing once again when we wanted expressions that
+// aren't just constants, but have variables in them, like x+1.
+
+type VariableExpression struct {
+	name string
+}
+
+func (v *VariableExpression) Accept(ev ExpressionVisitor) {
+	ev.VisitVariableExpression(v)
+}
+
 // Recap:
 // -> In this particular scenario we can't forget to handle
 // 	  a particular type of expression
@@ -245,6 +374,9 @@ func (ep *ExpressionPrinter) String() string {
 
 type ExpressionEvaluator struct {
 	result float64
+
+	// the values of the variables, see VisitVariableExpression
+	Variables map[string]float64
 }
 
 // And once again, we need to implement the Expression Visitor.
@@ -261,6 +393,272 @@ func (ee *ExpressionEvaluator) VisitAdditionExpression(e *AdditionExpression) {
 	ee.result = x
 }
 
+// And since any number of visitors can go over the same tree,
+// we can put the printer and the evaluator side by side, and get
+// both the expression and its value in one go.
+
+func CompareVisitors(e Expression) (printed string, value float64) {
+	ep := NewExpressionPrinter()
+	e.Accept(ep)
+	ee := &ExpressionEvaluator{}
+	e.Accept(ee)
+	return ep.String(), ee.result
+}
+
+// <- The tree doesn't change, neither visitor knows about the other,
+//	  and each of them keeps its result to itself until we ask for it.
+
+func (ee *ExpressionEvaluator) VisitDivisionExpression(e *DivisionExpression) {
+	e.left.Accept(ee)
+	x := ee.result
+	e.right.Accept(ee)
+	x /= ee.result
+	ee.result = x
+}
+
+func (ee *ExpressionEvaluator) VisitVariableExpression(e *VariableExpression) {
+	ee.result = ee.Variables[e.name]
+}
+
+// <- A variable nobody gave us a value for is simply 0,
+//	  just like a missing key in any other Go map.
+
+// And it doesn't have to end with printing for humans.
+// A visitor can just as well target a completely different
+// textual output, for example, Go source code.
+
+// The idea is that we generate an expression that
+// could be pasted straight into some Go program.
+// The only real difference from the printer is that
+// doubles always need to look like floats, so 1 becomes 1.0.
+
+type GoSourceVisitor struct {
+	sb strings.Builder
+}
+
+func NewGoSourceVisitor() *GoSourceVisitor {
+	return &GoSourceVisitor{strings.Builder{}}
+}
+
+func (gs *GoSourceVisitor) VisitDoubleExpression(e *DoubleExpression) {
+	s := strconv.FormatFloat(e.value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	gs.sb.WriteString(s)
+}
+
+func (gs *GoSourceVisitor) VisitAdditionExpression(e *AdditionExpression) {
+	gs.sb.WriteRune('(')
+	e.left.Accept(gs)
+	gs.sb.WriteString(" + ")
+	e.right.Accept(gs)
+	gs.sb.WriteRune(')')
+}
+
+func (gs *GoSourceVisitor) VisitDivisionExpression(e *DivisionExpression) {
+	gs.sb.WriteRune('(')
+	e.left.Accept(gs)
+	gs.sb.WriteString(" / ")
+	e.right.Accept(gs)
+	gs.sb.WriteRune(')')
+}
+
+func (gs *GoSourceVisitor) VisitVariableExpression(e *VariableExpression) {
+	gs.sb.WriteString(e.name)
+}
+
+// <- The variable better exist in the Go program as a float64.
+
+func (gs *GoSourceVisitor) String() string {
+	return gs.sb.String()
+}
+
+// A visitor also doesn't have to produce a string or a number.
+// It can produce a whole new expression, for example a simplified one,
+// where every subtree made up only of constants is folded into a single
+// constant, so 2+3 simply becomes 5.
+
+type ExpressionSimplifier struct {
+	result Expression
+}
+
+func (es *ExpressionSimplifier) VisitDoubleExpression(e *DoubleExpression) {
+	es.result = e
+}
+
+func (es *ExpressionSimplifier) VisitAdditionExpression(e *AdditionExpression) {
+	e.left.Accept(es)
+	left := es.result
+	e.right.Accept(es)
+	right := es.result
+
+	l, lok := left.(*DoubleExpression)
+	r, rok := right.(*DoubleExpression)
+	if lok && rok {
+		es.result = &DoubleExpression{l.value + r.value}
+	} else {
+		es.result = &AdditionExpression{left, right}
+	}
+}
+
+func (es *ExpressionSimplifier) VisitDivisionExpression(e *DivisionExpression) {
+	e.left.Accept(es)
+	left := es.result
+	e.right.Accept(es)
+	right := es.result
+
+	l, lok := left.(*DoubleExpression)
+	r, rok := right.(*DoubleExpression)
+	if lok && rok && r.value != 0 {
+		es.result = &DoubleExpression{l.value / r.value}
+	} else {
+		es.result = &DivisionExpression{left, right}
+	}
+}
+
+// <- Dividing by zero is left for whoever evaluates the expression,
+//	  folding it would just hide the problem.
+
+// And a variable is the one thing that can't be folded, we have no
+// idea what its value is going to be, so it stays exactly as it is.
+
+func (es *ExpressionSimplifier) VisitVariableExpression(e *VariableExpression) {
+	es.result = e
+}
+
+// <- And since it's not a DoubleExpression, every operation it takes
+//	  part in stays as well, so in (1+2)+x only the 1+2 gets folded.
+
+// Notice that the original tree is left alone, we build a new one.
+
+func Simplify(e Expression) Expression {
+	es := &ExpressionSimplifier{}
+	e.Accept(es)
+	return es.result
+}
+
+// Sometimes we don't care about the structure of the expression
+// at all, only about the numbers in it, say to find the biggest one.
+// So we can have a visitor that just collects them, left to right.
+
+type LeafCollector struct {
+	Values []float64
+}
+
+func (lc *LeafCollector) VisitDoubleExpression(e *DoubleExpression) {
+	lc.Values = append(lc.Values, e.value)
+}
+
+func (lc *LeafCollector) VisitAdditionExpression(e *AdditionExpression) {
+	e.left.Accept(lc)
+	e.right.Accept(lc)
+}
+
+func (lc *LeafCollector) VisitDivisionExpression(e *DivisionExpression) {
+	e.left.Accept(lc)
+	e.right.Accept(lc)
+}
+
+func (lc *LeafCollector) VisitVariableExpression(e *VariableExpression) {}
+
+// <- A variable is a leaf too, but there's no number in it to collect.
+
+// And before we evaluate something, we might want to know how
+// expensive that's going to be, say to decide whether it's worth
+// caching the result, or which of two equivalent expressions to pick.
+// Not every operation costs the same, a division is a lot slower
+// than an addition, so every kind of node gets its own weight.
+
+type OperationCosts struct {
+	Constant, Addition, Division float64
+}
+
+var DefaultCosts = OperationCosts{Constant: 0, Addition: 1, Division: 4}
+
+type CostVisitor struct {
+	Costs OperationCosts
+	Total float64
+}
+
+func NewCostVisitor(costs OperationCosts) *CostVisitor {
+	return &CostVisitor{Costs: costs}
+}
+
+func (cv *CostVisitor) VisitDoubleExpression(e *DoubleExpression) {
+	cv.Total += cv.Costs.Constant
+}
+
+func (cv *CostVisitor) VisitAdditionExpression(e *AdditionExpression) {
+	cv.Total += cv.Costs.Addition
+	e.left.Accept(cv)
+	e.right.Accept(cv)
+}
+
+func (cv *CostVisitor) VisitDivisionExpression(e *DivisionExpression) {
+	cv.Total += cv.Costs.Division
+	e.left.Accept(cv)
+	e.right.Accept(cv)
+}
+
+func (cv *CostVisitor) VisitVariableExpression(e *VariableExpression) {
+	cv.Total += cv.Costs.Constant // <- reading it is about as cheap
+}
+
+// <- The weights are just numbers, whoever knows their hardware
+//	  better than we do is free to pass in their own.
+
+// If the printed expression ends up in some editor, we'd like
+// to know which part of the output came from which part of the tree,
+// so that when somebody clicks on a character, we can highlight
+// the whole subexpression it belongs to.
+
+// So the printer can remember, for every node it prints, where
+// its text starts and where it ends.
+
+type SourceSpan struct {
+	Start, End int // <- byte offsets, End is exclusive
+	Node       Expression
+}
+
+func NewMappingPrinter() *ExpressionPrinter {
+	return &ExpressionPrinter{sb: &strings.Builder{}, mapping: true}
+}
+
+// <- Every visit defers a mark with the offset it started at,
+//	  so the span gets closed once the whole node has been written.
+
+func (ep *ExpressionPrinter) mark(node Expression, start int) {
+	if ep.mapping {
+		ep.spans = append(ep.spans, SourceSpan{start, ep.sb.Len(), node})
+	}
+}
+
+func (ep *ExpressionPrinter) SourceMap() []SourceSpan {
+	return ep.spans
+}
+
+// A character is inside every node that encloses it, all the way
+// up to the root, and what we want is the innermost one, which is
+// simply the shortest span containing it.
+
+func (ep *ExpressionPrinter) NodeAt(offset int) (Expression, bool) {
+	var best *SourceSpan
+	for i, span := range ep.spans {
+		if span.Start <= offset && offset < span.End &&
+			(best == nil || span.End-span.Start < best.End-best.Start) {
+			best = &ep.spans[i]
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+	return best.Node, true
+}
+
+// <- The parentheses and the operator belong to the
+//	  operation itself, not to any of its operands.
+
 func main() {
 	e := &AdditionExpression{
 		left: &DoubleExpression{1},
@@ -274,8 +672,66 @@ func main() {
 	e.Accept(ep)
 	// fmt.Println(ep.String())
 
-	ee := &ExpressionEvaluator{}
-	e.Accept(ee)
+	printed, value := CompareVisitors(e)
+	fmt.Printf("%s = %g\n", printed, value)
+
+	gs := NewGoSourceVisitor()
+	e.Accept(gs)
+	fmt.Println("x :=", gs) // x := (1.0 + (2.0 + 3.0))
+
+	sp := NewExpressionPrinter()
+	Simplify(e).Accept(sp)
+	fmt.Println("Simplified:", sp)
+
+	withX := &AdditionExpression{e, &VariableExpression{"x"}}
+	xp := NewExpressionPrinter()
+	Simplify(withX).Accept(xp)
+	fmt.Println("Simplified with a variable:", xp) // (6+x)
+
+	lp := NewDepthLimitedPrinter(1)
+	e.Accept(lp)
+	fmt.Println(lp, "truncated:", lp.Truncated())
 
-	fmt.Printf("%s = %g", ep, ee.result)
+	fp := NewFormattedPrinter(PrintOptions{SpaceAroundOperators: true})
+	e.Accept(fp)
+	fmt.Println("Spaced:", fp, "compact:", ep)
+
+	report := &strings.Builder{}
+	for _, expr := range []Expression{e, Simplify(e)} {
+		PrintInto(expr, report)
+		report.WriteRune('\n')
+	}
+	fmt.Print("Report:\n", report) // <- see the benchmarks for what this saves
+
+	lc := &LeafCollector{}
+	e.Accept(lc)
+	fmt.Println("Leaves:", lc.Values)
+
+	d := &AdditionExpression{
+		left: &DoubleExpression{1},
+		right: &DivisionExpression{
+			left:  &DoubleExpression{2},
+			right: &DoubleExpression{3},
+		},
+	}
+	dp := NewFormattedPrinter(PrintOptions{MathSymbols: true})
+	d.Accept(dp)
+	de := &ExpressionEvaluator{}
+	d.Accept(de)
+	fmt.Printf("%s = %.4g\n", dp, de.result)
+
+	ec, dc := NewCostVisitor(DefaultCosts), NewCostVisitor(DefaultCosts)
+	e.Accept(ec)
+	d.Accept(dc)
+	fmt.Printf("Cost of %s is %g, cost of %s is %g\n", ep, ec.Total, dp, dc.Total)
+
+	mp := NewMappingPrinter()
+	sum := &AdditionExpression{&DoubleExpression{1}, &DoubleExpression{2}}
+	sum.Accept(mp)
+	for offset := range mp.String() {
+		node, _ := mp.NodeAt(offset)
+		np := NewExpressionPrinter()
+		node.Accept(np)
+		fmt.Printf("%s at %d is part of %s\n", mp.String()[offset:offset+1], offset, np)
+	}
 }
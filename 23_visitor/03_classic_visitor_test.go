@@ -0,0 +1,246 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sampleExpression() Expression {
+	return &AdditionExpression{
+		left: &DoubleExpression{1},
+		right: &AdditionExpression{
+			left:  &DoubleExpression{2},
+			right: &DoubleExpression{3},
+		},
+	}
+}
+
+func divisionExpression() Expression {
+	return &AdditionExpression{
+		left: &DoubleExpression{1},
+		right: &DivisionExpression{
+			left:  &DoubleExpression{2},
+			right: &DoubleExpression{3},
+		},
+	}
+}
+
+func render(e Expression) string {
+	ep := NewExpressionPrinter()
+	e.Accept(ep)
+	return ep.String()
+}
+
+func TestCompareVisitors(t *testing.T) {
+	printed, value := CompareVisitors(sampleExpression())
+	if printed != "(1+(2+3))" || value != 6 {
+		t.Fatalf("got %s = %g", printed, value)
+	}
+	two, three := 2.0, 3.0
+	if _, value := CompareVisitors(divisionExpression()); value != 1+two/three {
+		t.Fatalf("division: got %g", value)
+	}
+}
+
+func TestGoSourceVisitor(t *testing.T) {
+	tests := map[string]Expression{
+		"(1.0 + (2.0 + 3.0))": sampleExpression(),
+		"(1.0 + (2.0 / 3.0))": divisionExpression(),
+		"0.5":                 &DoubleExpression{0.5},
+		"1e+21":               &DoubleExpression{1e21},
+	}
+	for want, e := range tests {
+		gs := NewGoSourceVisitor()
+		e.Accept(gs)
+		if gs.String() != want {
+			t.Errorf("got %s, want %s", gs, want)
+		}
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	e := sampleExpression()
+	if got := render(Simplify(e)); got != "6" {
+		t.Errorf("got %s, want 6", got)
+	}
+	if got := render(e); got != "(1+(2+3))" {
+		t.Errorf("simplifying changed the original: %s", got)
+	}
+
+	byZero := &AdditionExpression{
+		left:  &AdditionExpression{&DoubleExpression{1}, &DoubleExpression{2}},
+		right: &DivisionExpression{&DoubleExpression{1}, &DoubleExpression{0}},
+	}
+	if got := render(Simplify(byZero)); got != "(3+(1/0))" {
+		t.Errorf("got %s, want (3+(1/0))", got)
+	}
+}
+
+func TestSimplifyKeepsVariables(t *testing.T) {
+	x := &VariableExpression{"x"}
+	e := &AdditionExpression{
+		left: &AdditionExpression{&DoubleExpression{1}, &DoubleExpression{2}},
+		right: &DivisionExpression{
+			left:  x,
+			right: &AdditionExpression{&DoubleExpression{3}, &DoubleExpression{1}},
+		},
+	}
+	simplified := Simplify(e)
+	if got := render(simplified); got != "(3+(x/4))" {
+		t.Fatalf("got %s, want (3+(x/4))", got)
+	}
+	if simplified.(*AdditionExpression).right.(*DivisionExpression).left != x {
+		t.Error("the variable was replaced")
+	}
+
+	for value, want := range map[float64]float64{0: 3, 8: 5} {
+		ee := &ExpressionEvaluator{Variables: map[string]float64{"x": value}}
+		simplified.Accept(ee)
+		if ee.result != want {
+			t.Errorf("x = %g: got %g, want %g", value, ee.result, want)
+		}
+	}
+}
+
+func TestDepthLimitedPrinter(t *testing.T) {
+	tests := []struct {
+		maxDepth  int
+		want      string
+		truncated bool
+	}{
+		{0, "(1+(2+3))", false},
+		{1, "(1+…)", true},
+		{2, "(1+(2+3))", false},
+	}
+	for _, tt := range tests {
+		lp := NewDepthLimitedPrinter(tt.maxDepth)
+		sampleExpression().Accept(lp)
+		if lp.String() != tt.want || lp.Truncated() != tt.truncated {
+			t.Errorf("depth %d: got %s, %v", tt.maxDepth, lp, lp.Truncated())
+		}
+	}
+}
+
+func TestDepthLimitedPrinterOnDeepChain(t *testing.T) {
+	// 1+(1+(1+...)), ten thousand additions deep
+	var e Expression = &DoubleExpression{1}
+	for i := 0; i < 10000; i++ {
+		e = &AdditionExpression{&DoubleExpression{1}, e}
+	}
+
+	lp := NewDepthLimitedPrinter(50)
+	e.Accept(lp)
+	want := strings.Repeat("(1+", 50) + "…" + strings.Repeat(")", 50)
+	if lp.String() != want {
+		t.Fatalf("got %s", lp)
+	}
+	if !lp.Truncated() {
+		t.Fatal("a 10000 deep chain was not truncated at 50")
+	}
+}
+
+func TestFormattedPrinter(t *testing.T) {
+	tests := []struct {
+		opts PrintOptions
+		want string
+	}{
+		{PrintOptions{}, "(1+(2/3))"},
+		{PrintOptions{SpaceAroundOperators: true}, "(1 + (2 / 3))"},
+		{PrintOptions{MathSymbols: true}, "(1+(2÷3))"},
+		{PrintOptions{true, true}, "(1 + (2 ÷ 3))"},
+	}
+	for _, tt := range tests {
+		fp := NewFormattedPrinter(tt.opts)
+		divisionExpression().Accept(fp)
+		if fp.String() != tt.want {
+			t.Errorf("%+v: got %s, want %s", tt.opts, fp, tt.want)
+		}
+	}
+}
+
+func TestLeafCollector(t *testing.T) {
+	lc := &LeafCollector{}
+	divisionExpression().Accept(lc)
+	if !reflect.DeepEqual(lc.Values, []float64{1, 2, 3}) {
+		t.Fatalf("got %v", lc.Values)
+	}
+}
+
+func TestCostVisitor(t *testing.T) {
+	for e, want := range map[Expression]float64{
+		sampleExpression():   2,
+		divisionExpression(): 5,
+	} {
+		cv := NewCostVisitor(DefaultCosts)
+		e.Accept(cv)
+		if cv.Total != want {
+			t.Errorf("%s: cost %g, want %g", render(e), cv.Total, want)
+		}
+	}
+
+	cv := NewCostVisitor(OperationCosts{Constant: 1})
+	divisionExpression().Accept(cv)
+	if cv.Total != 3 {
+		t.Errorf("constants only: cost %g, want 3", cv.Total)
+	}
+}
+
+func TestSourceMap(t *testing.T) {
+	left, right := &DoubleExpression{1}, &DoubleExpression{2}
+	sum := &AdditionExpression{left, right}
+	mp := NewMappingPrinter()
+	sum.Accept(mp)
+
+	want := []SourceSpan{{1, 2, left}, {3, 4, right}, {0, 5, sum}}
+	if !reflect.DeepEqual(mp.SourceMap(), want) {
+		t.Fatalf("got %+v", mp.SourceMap())
+	}
+
+	for offset, node := range []Expression{sum, left, sum, right, sum} {
+		if got, ok := mp.NodeAt(offset); !ok || got != node {
+			t.Errorf("offset %d: got %v", offset, got)
+		}
+	}
+	if _, ok := mp.NodeAt(5); ok {
+		t.Error("found a node past the end")
+	}
+	if len(NewExpressionPrinter().SourceMap()) != 0 {
+		t.Error("a plain printer recorded a source map")
+	}
+}
+
+func TestPrintIntoAppends(t *testing.T) {
+	report := &strings.Builder{}
+	report.WriteString("e = ")
+	PrintInto(sampleExpression(), report)
+	if got := report.String(); got != "e = (1+(2+3))" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func BenchmarkPrintOwnBuilder(b *testing.B) {
+	e := sampleExpression()
+	report := &strings.Builder{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := NewExpressionPrinter()
+		e.Accept(p)
+		report.WriteString(p.String())
+		if report.Len() > 1<<20 {
+			report.Reset()
+		}
+	}
+}
+
+func BenchmarkPrintInto(b *testing.B) {
+	e := sampleExpression()
+	report := &strings.Builder{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		PrintInto(e, report)
+		if report.Len() > 1<<20 {
+			report.Reset()
+		}
+	}
+}